@@ -2,6 +2,7 @@ package goutils
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha256"
@@ -13,7 +14,6 @@ import (
 	logger "github.com/sirupsen/logrus"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
@@ -22,14 +22,51 @@ import (
 	"time"
 )
 
+// BotSender 机器人消息发送接口。(webhook 返回非 2xx 响应状态时返回 *HttpError 错误)
 type BotSender interface {
 	Send(v BotMessage) error
 }
 
+// BotContextSender 支持 context 的 BotSender。ctx 取消时中止发送频率等待及 HTTP 请求。
+type BotContextSender interface {
+	BotSender
+	SendContext(ctx context.Context, v BotMessage) error
+}
+
 type BotMessage interface {
 	Body() ([]byte, error)
 }
 
+// BotError 机器人平台返回的业务错误 (响应中的错误码不为 0)。(可通过 errors.As 获取)
+type BotError struct {
+	// 平台 (feishu, dingtalk, wxwork)
+	Platform string
+	Code     int
+	Message  string
+}
+
+func (e *BotError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// 各平台表示发送频率超限的错误码
+var botThrottleCodes = map[string][]int{
+	"feishu":   {11232},
+	"dingtalk": {130101, 410100},
+	"wxwork":   {45009, 45033},
+}
+
+// Throttled 是否因发送频率超限而失败？
+func (e *BotError) Throttled() bool {
+	for _, code := range botThrottleCodes[e.Platform] {
+		if code == e.Code {
+			return true
+		}
+	}
+
+	return false
+}
+
 type feishuMessage struct {
 	Timestamp string `json:"timestamp,omitempty"`
 	Sign      string `json:"sign,omitempty"`
@@ -145,13 +182,23 @@ func (s *FeishuRichMessage) AddAt(userId string) {
 	})
 }
 
+type feishuCardText struct {
+	Content string `json:"content"`
+	Tag     string `json:"tag"`
+}
+
 type feishuCardMessageElement struct {
-	Tag  string `json:"tag"`
-	Text struct {
-		Content string `json:"content"`
-		Tag     string `json:"tag"`
-	} `json:"text,omitempty"`
+	Tag     string                           `json:"tag"`
+	Text    *feishuCardText                  `json:"text,omitempty"`
+	Fields  []feishuCardMessageField         `json:"fields,omitempty"`
 	Actions []feishuCardMessageElementAction `json:"actions,omitempty"`
+	ImgKey  string                           `json:"img_key,omitempty"`
+	Alt     *feishuCardText                  `json:"alt,omitempty"`
+}
+
+type feishuCardMessageField struct {
+	IsShort bool           `json:"is_short"`
+	Text    feishuCardText `json:"text"`
 }
 
 type feishuCardMessageElementAction struct {
@@ -178,6 +225,7 @@ type FeishuCardMessage struct {
 				Content string `json:"content"`
 				Tag     string `json:"tag"`
 			} `json:"title"`
+			Template string `json:"template,omitempty"` // 标题栏颜色 (blue, green, orange, red 等)
 		} `json:"header"`
 	} `json:"card"`
 }
@@ -213,10 +261,9 @@ func NewFeishuCardMessage(title string) *FeishuCardMessage {
 
 func (s *FeishuCardMessage) AddLineContent(v string) {
 	elem := feishuCardMessageElement{
-		Tag: "div",
+		Tag:  "div",
+		Text: &feishuCardText{Tag: "lark_md", Content: v},
 	}
-	elem.Text.Tag = "lark_md"
-	elem.Text.Content = v
 
 	s.Card.Elements = append(s.Card.Elements, elem)
 }
@@ -246,10 +293,92 @@ func (s *FeishuCardMessage) AddButton(label, href string) {
 	s.Card.Elements = append(s.Card.Elements, elem)
 }
 
+// AddImage 添加图片 (imageKey 通过 FeishuBotSender.UploadImage 获取)。
+func (s *FeishuCardMessage) AddImage(imageKey, alt string) {
+	elem := feishuCardMessageElement{
+		Tag:    "img",
+		ImgKey: imageKey,
+		Alt:    &feishuCardText{Tag: "plain_text", Content: alt},
+	}
+
+	s.Card.Elements = append(s.Card.Elements, elem)
+}
+
 type FeishuBotSender struct {
 	AccessToken       string
 	SecretKey         string
-	TenantAccessToken string // 租户访问凭证, 用于上传图片
+	TenantAccessToken string           // 租户访问凭证, 用于上传图片
+	TenantAuth        HttpAuthProvider // 租户访问凭证自动获取 (例如: &FeishuTenantAccessToken{}), 优先于 TenantAccessToken
+	Retry             *HttpRetryPolicy // 重试策略 (webhook 为 POST 请求, 需开启 RetryNonIdempotent)
+	Client            *HttpClient      // HTTP 客户端 (默认值: 按 Retry 创建的 HttpClient)
+	RateLimits        []BotRateLimit   // 发送频率限制 (默认值: FeishuBotRateLimits), 相同 AccessToken 的发送器共享
+	DisableRateLimit  bool             // 是否关闭发送频率限制？
+}
+
+func (s *FeishuBotSender) client() *HttpClient {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	return NewHttpClient(HttpClientOptionWithRetry(s.Retry))
+}
+
+// FeishuTenantAccessToken 飞书自建应用租户访问凭证。过期前自动刷新。
+type FeishuTenantAccessToken struct {
+	AppID     string
+	AppSecret string
+	// 获取凭证使用的 HTTP 客户端 (默认值: NewHttpClient())
+	Client *HttpClient
+
+	cache cachedToken
+}
+
+func (t *FeishuTenantAccessToken) Authorize(req *http.Request) error {
+	token, err := t.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+// Token 获取租户访问凭证。(剩余有效期不足 5 分钟时重新获取)
+func (t *FeishuTenantAccessToken) Token(ctx context.Context) (string, error) {
+	return t.cache.get(time.Minute*5, func() (string, time.Duration, error) {
+		client := t.Client
+		if client == nil {
+			client = NewHttpClient()
+		}
+
+		resp, err := client.PostContext(ctx, "https://open.feishu.cn/open-apis/auth/v3/tenant_access_token/internal", &HttpRequest{
+			JSON: map[string]string{
+				"app_id":     t.AppID,
+				"app_secret": t.AppSecret,
+			},
+		})
+		if err != nil {
+			return "", 0, err
+		}
+
+		r1 := struct {
+			Code              int    `json:"code"`
+			Msg               string `json:"msg"`
+			TenantAccessToken string `json:"tenant_access_token"`
+			Expire            int    `json:"expire"`
+		}{}
+
+		if err = resp.ToJson(&r1); err != nil {
+			return "", 0, errors.Errorf("Response parse error: %v", err)
+		}
+
+		if r1.Code != 0 {
+			return "", 0, errors.Errorf("%s (%d)", r1.Msg, r1.Code)
+		}
+
+		return r1.TenantAccessToken, time.Duration(r1.Expire) * time.Second, nil
+	})
 }
 
 func (s *FeishuBotSender) sign(v interface{}) error {
@@ -289,34 +418,27 @@ func (s *FeishuBotSender) UploadImage(filename string) (string, error) {
 		return "", errors.New("Source file does not exist.")
 	}
 
-	file, _ := os.Open(filename)
-	defer file.Close()
+	var auth HttpAuthProvider = &HttpBearerAuth{Token: s.TenantAccessToken}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	defer writer.Close()
-	part, _ := writer.CreateFormFile("image", filepath.Base(file.Name()))
-	if _, err := io.Copy(part, file); err != nil {
-		return "", err
+	if s.TenantAuth != nil {
+		auth = s.TenantAuth
 	}
-	writer.WriteField("image_type", "message")
 
-	r, _ := http.NewRequest("POST", "https://open.feishu.cn/open-apis/im/v1/images", body)
-	r.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.TenantAccessToken))
-	r.Header.Add("Content-Type", writer.FormDataContentType())
-	client := &http.Client{}
-	resp, err := client.Do(r)
+	client := s.client()
+	resp, err := client.Post("https://open.feishu.cn/open-apis/im/v1/images", &HttpRequest{
+		Auth: auth,
+		FormParams: map[string]interface{}{
+			"image_type": "message",
+		},
+		Files: []*HttpFormFile{
+			{FieldName: "image", Path: filename},
+		},
+	})
 	if err != nil {
 		return "", err
 	}
-	if resp.StatusCode != 200 {
-		return "", errors.Errorf("Response status error: %d", resp.StatusCode)
-	}
 
-	content, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
+	content := resp.Body
 
 	// fmt.Println(fmt.Sprintf("Result: %s", string(content)))
 	logger.Debugf("Result: %s", string(content))
@@ -341,10 +463,21 @@ func (s *FeishuBotSender) UploadImage(filename string) (string, error) {
 }
 
 func (s *FeishuBotSender) Send(v BotMessage) error {
+	return s.SendContext(context.Background(), v)
+}
+
+// SendContext 发送消息。ctx 取消时中止发送频率等待及 HTTP 请求。
+func (s *FeishuBotSender) SendContext(ctx context.Context, v BotMessage) error {
 	if s.AccessToken == "" {
 		return errors.New("Access token is invalid.")
 	}
 
+	// 等待至发送频率允许 (签名时间戳需在等待后生成)
+	limiter := s.limiter()
+	if err := limiter.wait(ctx); err != nil {
+		return err
+	}
+
 	s.sign(v)
 
 	data, err := v.Body()
@@ -352,8 +485,8 @@ func (s *FeishuBotSender) Send(v BotMessage) error {
 		return err
 	}
 
-	client := NewHttpClient()
-	resp, err := client.Post(fmt.Sprintf("https://open.feishu.cn/open-apis/bot/v2/hook/%s", s.AccessToken), &HttpRequest{
+	client := s.client()
+	resp, err := client.PostContext(ctx, fmt.Sprintf("https://open.feishu.cn/open-apis/bot/v2/hook/%s", s.AccessToken), &HttpRequest{
 		JSON: data,
 	})
 	if err != nil {
@@ -374,7 +507,12 @@ func (s *FeishuBotSender) Send(v BotMessage) error {
 	}
 
 	if r1.Code != 0 {
-		return errors.Errorf("%s (%d)", r1.Msg, r1.Code)
+		e := &BotError{Platform: "feishu", Code: r1.Code, Message: r1.Msg}
+		if e.Throttled() {
+			limiter.throttled()
+		}
+
+		return e
 	}
 
 	logger.Debugf("Response: %v", resp)
@@ -383,8 +521,20 @@ func (s *FeishuBotSender) Send(v BotMessage) error {
 }
 
 type DingtalkBotSender struct {
-	AccessToken string
-	SecretKey   string
+	AccessToken      string
+	SecretKey        string
+	Retry            *HttpRetryPolicy // 重试策略 (webhook 为 POST 请求, 需开启 RetryNonIdempotent)
+	Client           *HttpClient      // HTTP 客户端 (默认值: 按 Retry 创建的 HttpClient)
+	RateLimits       []BotRateLimit   // 发送频率限制 (默认值: DingtalkBotRateLimits), 相同 AccessToken 的发送器共享
+	DisableRateLimit bool             // 是否关闭发送频率限制？
+}
+
+func (s *DingtalkBotSender) client() *HttpClient {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	return NewHttpClient(HttpClientOptionWithRetry(s.Retry))
 }
 
 type DingtalkTextMessage struct {
@@ -626,10 +776,21 @@ func (s *DingtalkFeedCardMessage) Body() ([]byte, error) {
 }
 
 func (s *DingtalkBotSender) Send(v BotMessage) error {
+	return s.SendContext(context.Background(), v)
+}
+
+// SendContext 发送消息。ctx 取消时中止发送频率等待及 HTTP 请求。
+func (s *DingtalkBotSender) SendContext(ctx context.Context, v BotMessage) error {
 	if s.AccessToken == "" {
 		return errors.New("Access token is invalid.")
 	}
 
+	// 等待至发送频率允许 (签名时间戳需在等待后生成)
+	limiter := s.limiter()
+	if err := limiter.wait(ctx); err != nil {
+		return err
+	}
+
 	data, err := v.Body()
 	if err != nil {
 		return err
@@ -654,8 +815,8 @@ func (s *DingtalkBotSender) Send(v BotMessage) error {
 		value.Set("access_token", s.AccessToken)
 	}
 
-	client := NewHttpClient()
-	resp, err := client.Post(fmt.Sprintf("https://oapi.dingtalk.com/robot/send?%s", value.Encode()), &HttpRequest{
+	client := s.client()
+	resp, err := client.PostContext(ctx, fmt.Sprintf("https://oapi.dingtalk.com/robot/send?%s", value.Encode()), &HttpRequest{
 		JSON: data,
 	})
 	if err != nil {
@@ -672,7 +833,12 @@ func (s *DingtalkBotSender) Send(v BotMessage) error {
 	}
 
 	if r1.Errcode != 0 {
-		return errors.Errorf("%s (%d)", r1.Errmsg, r1.Errcode)
+		e := &BotError{Platform: "dingtalk", Code: r1.Errcode, Message: r1.Errmsg}
+		if e.Throttled() {
+			limiter.throttled()
+		}
+
+		return e
 	}
 
 	logger.Debugf("Response: %v", resp)
@@ -1009,48 +1175,51 @@ func NewWxWorkNewsNoticeMessage() *WxWorkNewsNoticeMessage {
 }
 
 type WxWorkBotSender struct {
-	AccessToken string
+	AccessToken      string
+	Retry            *HttpRetryPolicy // 重试策略 (webhook 为 POST 请求, 需开启 RetryNonIdempotent)
+	Client           *HttpClient      // HTTP 客户端 (默认值: 按 Retry 创建的 HttpClient)
+	RateLimits       []BotRateLimit   // 发送频率限制 (默认值: WxWorkBotRateLimits), 相同 AccessToken 的发送器共享
+	DisableRateLimit bool             // 是否关闭发送频率限制？
 }
 
-func (s *WxWorkBotSender) UploadMedia(filename string) (string, error) {
-	if !IsFile(filename) {
-		return "", errors.New("Source file does not exist.")
+func (s *WxWorkBotSender) client() *HttpClient {
+	if s.Client != nil {
+		return s.Client
 	}
 
-	file, _ := os.Open(filename)
-	defer file.Close()
-	stat, _ := file.Stat()
-
-	fileName := filepath.Base(filename)
-	fileSize := stat.Size()
+	return NewHttpClient(HttpClientOptionWithRetry(s.Retry))
+}
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	defer writer.Close()
-	part, _ := writer.CreateFormFile("file", filepath.Base(file.Name()))
-	if _, err := io.Copy(part, file); err != nil {
-		return "", err
+func (s *WxWorkBotSender) UploadMedia(filename string) (string, error) {
+	if !IsFile(filename) {
+		return "", errors.New("Source file does not exist.")
 	}
 
-	writer.WriteField("filename", fileName)
-	writer.WriteField("filelength", fmt.Sprintf("%d", fileSize))
-
-	r, _ := http.NewRequest("POST", fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/webhook/upload_media?key=%s&type=file", s.AccessToken), body)
-	r.Header.Add("Content-Type", writer.FormDataContentType())
-	client := &http.Client{}
-	resp, err := client.Do(r)
+	stat, err := os.Stat(filename)
 	if err != nil {
 		return "", err
 	}
-	if resp.StatusCode != 200 {
-		return "", errors.Errorf("Response status error: %d", resp.StatusCode)
-	}
 
-	content, err := ioutil.ReadAll(resp.Body)
+	value := url.Values{}
+	value.Set("key", s.AccessToken)
+	value.Set("type", "file")
+
+	client := s.client()
+	resp, err := client.Post(fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/webhook/upload_media?%s", value.Encode()), &HttpRequest{
+		FormParams: map[string]interface{}{
+			"filename":   filepath.Base(filename),
+			"filelength": fmt.Sprintf("%d", stat.Size()),
+		},
+		Files: []*HttpFormFile{
+			{FieldName: "file", Path: filename},
+		},
+	})
 	if err != nil {
 		return "", err
 	}
 
+	content := resp.Body
+
 	// fmt.Println(fmt.Sprintf("Result: %s", string(content)))
 	logger.Debugf("Result: %s", string(content))
 
@@ -1074,10 +1243,21 @@ func (s *WxWorkBotSender) UploadMedia(filename string) (string, error) {
 }
 
 func (s *WxWorkBotSender) Send(v BotMessage) error {
+	return s.SendContext(context.Background(), v)
+}
+
+// SendContext 发送消息。ctx 取消时中止发送频率等待及 HTTP 请求。
+func (s *WxWorkBotSender) SendContext(ctx context.Context, v BotMessage) error {
 	if s.AccessToken == "" {
 		return errors.New("Access token is invalid.")
 	}
 
+	// 等待至发送频率允许
+	limiter := s.limiter()
+	if err := limiter.wait(ctx); err != nil {
+		return err
+	}
+
 	data, err := v.Body()
 	if err != nil {
 		return err
@@ -1086,8 +1266,8 @@ func (s *WxWorkBotSender) Send(v BotMessage) error {
 	value := url.Values{}
 	value.Set("key", s.AccessToken)
 
-	client := NewHttpClient()
-	resp, err := client.Post(fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/webhook/send?%s", value.Encode()), &HttpRequest{
+	client := s.client()
+	resp, err := client.PostContext(ctx, fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/webhook/send?%s", value.Encode()), &HttpRequest{
 		JSON: data,
 	})
 	if err != nil {
@@ -1104,7 +1284,12 @@ func (s *WxWorkBotSender) Send(v BotMessage) error {
 	}
 
 	if r1.Errcode != 0 {
-		return errors.Errorf("%s (%d)", r1.Errmsg, r1.Errcode)
+		e := &BotError{Platform: "wxwork", Code: r1.Errcode, Message: r1.Errmsg}
+		if e.Throttled() {
+			limiter.throttled()
+		}
+
+		return e
 	}
 
 	logger.Debugf("Response: %v", resp)
@@ -0,0 +1,188 @@
+package goutils
+
+import (
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotificationDigest 突发通知合并。窗口内的第一条通知立即发送, 其余通知在窗口结束时合并为一条摘要发送, 以免超出平台的发送频率限制。
+//
+// 发送摘要后开始新的窗口, 直至某个窗口内没有新的通知。
+type NotificationDigest struct {
+	Sender NotificationSender
+	// 合并时间窗口 (默认值: 1min)
+	Window time.Duration
+	// 摘要中最多列出的通知数 (默认值: 20), 超出部分仅显示数量
+	MaxItems int
+	// 摘要发送失败时的回调 (未设置时输出日志)
+	OnError func(err error)
+
+	mu      sync.Mutex
+	pending []*Notification
+	timer   *time.Timer
+	// 窗口编号, 用于忽略已停止的定时器
+	gen int
+}
+
+// NewNotificationDigest 创建突发通知合并。
+func NewNotificationDigest(sender NotificationSender, window time.Duration) *NotificationDigest {
+	return &NotificationDigest{Sender: sender, Window: window}
+}
+
+// Notify 发送通知。窗口内的后续通知仅加入待发送列表并返回 nil, 发送错误通过 OnError 回调。
+func (d *NotificationDigest) Notify(n *Notification) error {
+	d.mu.Lock()
+	if d.timer != nil {
+		d.pending = append(d.pending, n)
+		d.mu.Unlock()
+		return nil
+	}
+	d.start()
+	d.mu.Unlock()
+
+	return SendNotification(d.Sender, n)
+}
+
+// Flush 立即发送待合并的通知并结束当前窗口。
+func (d *NotificationDigest) Flush() error {
+	d.mu.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.gen++
+	items := d.pending
+	d.pending = nil
+	d.mu.Unlock()
+
+	return d.send(items)
+}
+
+// Pending 返回待合并的通知数。
+func (d *NotificationDigest) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.pending)
+}
+
+// start 开始新的窗口。(需持有锁)
+func (d *NotificationDigest) start() {
+	window := d.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	d.gen++
+	gen := d.gen
+	d.timer = time.AfterFunc(window, func() {
+		d.tick(gen)
+	})
+}
+
+func (d *NotificationDigest) tick(gen int) {
+	d.mu.Lock()
+	if gen != d.gen {
+		d.mu.Unlock()
+		return
+	}
+
+	items := d.pending
+	d.pending = nil
+
+	if len(items) == 0 {
+		d.timer = nil
+	} else {
+		d.start()
+	}
+	d.mu.Unlock()
+
+	if err := d.send(items); err != nil {
+		if d.OnError != nil {
+			d.OnError(err)
+		} else {
+			logger.Errorf("Failed to send notification digest. (error: %v)", err)
+		}
+	}
+}
+
+func (d *NotificationDigest) send(items []*Notification) error {
+	switch len(items) {
+	case 0:
+		return nil
+	case 1:
+		return SendNotification(d.Sender, items[0])
+	}
+
+	return SendNotification(d.Sender, d.digest(items))
+}
+
+// digest 将多条通知合并为一条摘要: 使用最高的严重级别, 合并 @ 提醒的用户, 仅保留所有通知相同的标签。
+func (d *NotificationDigest) digest(items []*Notification) *Notification {
+	limit := d.MaxItems
+	if limit <= 0 {
+		limit = 20
+	}
+
+	n := &Notification{
+		Title:  fmt.Sprintf("%s 等 %d 条通知", items[0].Title, len(items)),
+		Labels: map[string]string{},
+	}
+
+	for k, v := range items[0].Labels {
+		n.Labels[k] = v
+	}
+
+	mentions := map[NotificationMention]bool{}
+
+	var lines []string
+
+	for i, v := range items {
+		if v.Severity > n.Severity {
+			n.Severity = v.Severity
+		}
+
+		for k, label := range n.Labels {
+			if v.Labels[k] != label {
+				delete(n.Labels, k)
+			}
+		}
+
+		n.MentionAll = n.MentionAll || v.MentionAll
+
+		for _, m := range v.Mentions {
+			if !mentions[m] {
+				mentions[m] = true
+				n.Mentions = append(n.Mentions, m)
+			}
+		}
+
+		if i < limit {
+			line := fmt.Sprintf("- **%s**", v.Title)
+			if summary := firstLine(v.Markdown); summary != "" {
+				line += " " + summary
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if len(items) > limit {
+		lines = append(lines, fmt.Sprintf("- 另有 %d 条通知", len(items)-limit))
+	}
+
+	n.Markdown = strings.Join(lines, "\n")
+
+	return n
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+
+	return s
+}
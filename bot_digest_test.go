@@ -0,0 +1,100 @@
+package goutils
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type testDigestSender struct {
+	mu   sync.Mutex
+	sent []*Notification
+}
+
+func (s *testDigestSender) Send(v BotMessage) error {
+	return nil
+}
+
+func (s *testDigestSender) Render(n *Notification) (BotMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, n)
+
+	return NewWxWorkMarkdownMessage(n.Title), nil
+}
+
+func (s *testDigestSender) notifications() []*Notification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Notification(nil), s.sent...)
+}
+
+func TestNotificationDigest_Notify(t *testing.T) {
+	sender := &testDigestSender{}
+	d := NewNotificationDigest(sender, 50*time.Millisecond)
+	d.MaxItems = 2
+
+	mention := NotificationMention{Name: "ops", WxWorkID: "ops"}
+
+	// 第一条立即发送, 其余合并为摘要
+	d.Notify(&Notification{Title: "job-1 failed", Labels: map[string]string{"team": "infra", "job": "1"}})
+	d.Notify(&Notification{Title: "job-2 failed", Markdown: "exit 1\nstack", Labels: map[string]string{"team": "infra", "job": "2"}, Mentions: []NotificationMention{mention}})
+	d.Notify(&Notification{Title: "job-3 failed", Severity: SeverityCritical, Labels: map[string]string{"team": "infra"}, Mentions: []NotificationMention{mention}})
+	d.Notify(&Notification{Title: "job-4 failed", Labels: map[string]string{"team": "infra"}})
+
+	if sent := sender.notifications(); len(sent) != 1 || sent[0].Title != "job-1 failed" || d.Pending() != 3 {
+		t.Fatalf("unexpected notifications: %d (pending: %d)", len(sent), d.Pending())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	sent := sender.notifications()
+	if len(sent) != 2 {
+		t.Fatalf("unexpected notifications: %d", len(sent))
+	}
+
+	digest := sent[1]
+	if digest.Title != "job-2 failed 等 3 条通知" || digest.Severity != SeverityCritical {
+		t.Errorf("unexpected digest: %s (%s)", digest.Title, digest.Severity)
+	}
+	if digest.Markdown != "- **job-2 failed** exit 1\n- **job-3 failed**\n- 另有 1 条通知" {
+		t.Errorf("unexpected markdown: %q", digest.Markdown)
+	}
+	if len(digest.Labels) != 1 || digest.Labels["team"] != "infra" || len(digest.Mentions) != 1 {
+		t.Errorf("unexpected labels/mentions: %v %v", digest.Labels, digest.Mentions)
+	}
+
+	// 发送摘要后的窗口内没有新通知, 窗口结束后下一条立即发送
+	time.Sleep(100 * time.Millisecond)
+	d.Notify(&Notification{Title: "job-5 failed"})
+	if sent = sender.notifications(); len(sent) != 3 || sent[2].Title != "job-5 failed" {
+		t.Errorf("unexpected notifications: %d", len(sent))
+	}
+}
+
+func TestNotificationDigest_Flush(t *testing.T) {
+	sender := &testDigestSender{}
+	d := NewNotificationDigest(sender, time.Hour)
+
+	d.Notify(NewNotification("a", ""))
+	d.Notify(NewNotification("b", ""))
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 单条待发送通知不合并
+	sent := sender.notifications()
+	if len(sent) != 2 || sent[1].Title != "b" || sent[0].Title != "a" {
+		t.Errorf("unexpected notifications: %d", len(sent))
+	}
+
+	// Flush 后开始新的窗口
+	d.Notify(NewNotification("c", ""))
+	if len(sender.notifications()) != 3 || d.Pending() != 0 {
+		t.Errorf("expected immediate send after flush")
+	}
+	d.Flush()
+}
@@ -0,0 +1,414 @@
+package goutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	logger "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrBotQueueFull 发送队列已满。
+var ErrBotQueueFull = errors.New("The bot message queue is full.")
+
+// ErrBotDispatcherClosed 发送器已关闭。
+var ErrBotDispatcherClosed = errors.New("The bot dispatcher is closed.")
+
+// BotDispatcher 异步消息发送器。消息进入有界队列后由后台协程发送, 临时性错误 (网络错误、429/5xx 响应及平台限流错误码) 按退避策略重试,
+// 最终发送失败的消息写入本地 JSONL 死信文件。
+type BotDispatcher struct {
+	sender         BotSender
+	queueSize      int
+	workers        int
+	retry          *HttpRetryPolicy
+	deadLetterFile string
+	retryable      func(err error) bool
+	onError        func(v BotMessage, err error)
+
+	queue  chan BotMessage
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+	// 关闭时通知阻塞在 SendContext 中的调用方
+	closing chan struct{}
+	// 阻塞在 SendContext 中的调用方 (关闭队列前需等待其退出)
+	senders sync.WaitGroup
+	// 关闭超时后取消重试等待, 将剩余消息写入死信文件
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	dlMu sync.Mutex
+}
+
+type BotDispatcherOption func(*BotDispatcher)
+
+// BotDispatcherOptionWithQueueSize 设置队列容量。(默认值: 1000)
+func BotDispatcherOptionWithQueueSize(size int) BotDispatcherOption {
+	return func(d *BotDispatcher) {
+		d.queueSize = size
+	}
+}
+
+// BotDispatcherOptionWithWorkers 设置并发发送的协程数。(默认值: 1, 即按入队顺序发送)
+func BotDispatcherOptionWithWorkers(n int) BotDispatcherOption {
+	return func(d *BotDispatcher) {
+		d.workers = n
+	}
+}
+
+// BotDispatcherOptionWithRetry 设置重试策略。(默认值: 最多尝试 5 次, 首次等待 1s, 最长等待 1min)
+func BotDispatcherOptionWithRetry(p *HttpRetryPolicy) BotDispatcherOption {
+	return func(d *BotDispatcher) {
+		d.retry = p
+	}
+}
+
+// BotDispatcherOptionWithDeadLetterFile 设置死信文件路径 (JSONL 格式)。未设置时仅通过日志输出。
+func BotDispatcherOptionWithDeadLetterFile(filename string) BotDispatcherOption {
+	return func(d *BotDispatcher) {
+		d.deadLetterFile = filename
+	}
+}
+
+// BotDispatcherOptionWithRetryable 自定义可重试错误的判断规则。(默认值: IsBotRetryableError)
+func BotDispatcherOptionWithRetryable(fn func(err error) bool) BotDispatcherOption {
+	return func(d *BotDispatcher) {
+		d.retryable = fn
+	}
+}
+
+// BotDispatcherOptionWithErrorHandler 设置消息最终发送失败时的回调。
+func BotDispatcherOptionWithErrorHandler(fn func(v BotMessage, err error)) BotDispatcherOption {
+	return func(d *BotDispatcher) {
+		d.onError = fn
+	}
+}
+
+// NewBotDispatcher 创建异步消息发送器并启动后台协程。使用完毕后需调用 Close 发送剩余消息。
+func NewBotDispatcher(sender BotSender, opts ...BotDispatcherOption) *BotDispatcher {
+	d := &BotDispatcher{
+		sender:    sender,
+		queueSize: 1000,
+		workers:   1,
+		retry: &HttpRetryPolicy{
+			MaxAttempts:     5,
+			InitialInterval: time.Second,
+			MaxInterval:     time.Minute,
+			Multiplier:      2,
+			Jitter:          0.2,
+		},
+		retryable: IsBotRetryableError,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.workers < 1 {
+		d.workers = 1
+	}
+
+	if d.queueSize < 0 {
+		d.queueSize = 0
+	}
+
+	d.queue = make(chan BotMessage, d.queueSize)
+	d.closing = make(chan struct{})
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+
+		go func() {
+			defer d.wg.Done()
+
+			for v := range d.queue {
+				d.deliver(v)
+			}
+		}()
+	}
+
+	return d
+}
+
+// Send 将消息加入发送队列, 队列已满时立即返回 ErrBotQueueFull。
+func (d *BotDispatcher) Send(v BotMessage) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrBotDispatcherClosed
+	}
+
+	select {
+	case d.queue <- v:
+		return nil
+	default:
+		return ErrBotQueueFull
+	}
+}
+
+// SendContext 将消息加入发送队列, 队列已满时等待至 ctx 取消或发送器关闭。
+func (d *BotDispatcher) SendContext(ctx context.Context, v BotMessage) error {
+	d.mu.RLock()
+	if d.closed {
+		d.mu.RUnlock()
+		return ErrBotDispatcherClosed
+	}
+	d.senders.Add(1)
+	d.mu.RUnlock()
+
+	defer d.senders.Done()
+
+	select {
+	case d.queue <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.closing:
+		return ErrBotDispatcherClosed
+	}
+}
+
+// Len 返回队列中等待发送的消息数。
+func (d *BotDispatcher) Len() int {
+	return len(d.queue)
+}
+
+// Close 停止接收新消息并等待队列中的消息发送完毕。ctx 取消时不再重试, 未发送的消息写入死信文件。
+// (发送器未实现 BotContextSender 时, 正在进行的 HTTP 请求不会被中断)
+func (d *BotDispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.closing)
+	d.mu.Unlock()
+
+	// 等待阻塞的 SendContext 退出后再关闭队列
+	d.senders.Wait()
+	close(d.queue)
+
+	done := make(chan struct{})
+
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// deliver 发送消息, 临时性错误按退避策略重试。
+func (d *BotDispatcher) deliver(v BotMessage) {
+	attempts := d.retry.maxAttempts()
+
+	var err error
+
+	for n := 1; ; n++ {
+		// 关闭超时后不再发送
+		if d.ctx.Err() != nil {
+			if err == nil {
+				err = ErrBotDispatcherClosed
+			}
+			break
+		}
+
+		if err = d.send(v); err == nil {
+			return
+		}
+
+		if n >= attempts || !d.retryable(err) {
+			break
+		}
+
+		wait := d.retry.backoff(n, retryResponse(err))
+
+		logger.Debugf("Bot message delivery failed, retrying in %s. (attempt: %d, error: %v)", wait, n, err)
+
+		if sleepContext(d.ctx, wait) != nil {
+			break
+		}
+	}
+
+	d.deadLetter(v, err)
+}
+
+// send 发送消息。发送器支持 context 时, 关闭超时可中止发送频率等待及 HTTP 请求。
+func (d *BotDispatcher) send(v BotMessage) error {
+	if s, ok := d.sender.(BotContextSender); ok {
+		return s.SendContext(d.ctx, v)
+	}
+
+	return d.sender.Send(v)
+}
+
+// retryResponse 返回 *HttpError 对应的响应对象, 以便遵循 Retry-After 响应头。
+func retryResponse(err error) *http.Response {
+	var he *HttpError
+	if errors.As(err, &he) {
+		return &http.Response{StatusCode: he.StatusCode, Header: he.Header}
+	}
+
+	return nil
+}
+
+// IsBotRetryableError 检查是否为临时性错误？(超时、连接被拒绝/重置等网络错误, 429/5xx 响应及平台限流错误码)
+//
+// 证书校验失败、域名不存在及不支持的协议等永久性错误不重试。
+func IsBotRetryableError(err error) bool {
+	var be *BotError
+	if errors.As(err, &be) {
+		return be.Throttled()
+	}
+
+	var he *HttpError
+	if errors.As(err, &he) {
+		return he.StatusCode == http.StatusTooManyRequests || he.StatusCode >= 500
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ETIMEDOUT) {
+		return true
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+
+	// DNS 服务器暂时不可用 (域名不存在时 IsTemporary 为 false)
+	var de *net.DNSError
+	if errors.As(err, &de) {
+		return de.IsTemporary || de.IsTimeout
+	}
+
+	return false
+}
+
+// BotDeadLetter 死信记录。(实现 BotMessage 接口, 可通过 LoadBotDeadLetters 读取后重新发送)
+type BotDeadLetter struct {
+	Time    time.Time       `json:"time"`
+	Sender  string          `json:"sender"`
+	Error   string          `json:"error"`
+	Message json.RawMessage `json:"message"`
+}
+
+func (l *BotDeadLetter) Body() ([]byte, error) {
+	return l.Message, nil
+}
+
+func (d *BotDispatcher) deadLetter(v BotMessage, err error) {
+	if d.onError != nil {
+		d.onError(v, err)
+	}
+
+	body, err2 := v.Body()
+	if err2 != nil || !json.Valid(body) {
+		b, _ := json.Marshal(string(body))
+		body = b
+	}
+
+	l := &BotDeadLetter{
+		Time:    time.Now(),
+		Sender:  fmt.Sprintf("%T", d.sender),
+		Error:   fmt.Sprint(err),
+		Message: body,
+	}
+
+	if d.deadLetterFile == "" {
+		logger.Errorf("Bot message delivery failed. (error: %v, message: %s)", err, body)
+		return
+	}
+
+	if err2 = d.writeDeadLetter(l); err2 != nil {
+		logger.Errorf("Failed to write dead letter. (error: %v, message: %s)", err2, body)
+	}
+}
+
+// writeDeadLetter 以追加方式写入一行 JSON。(单次 Write 调用, 避免多个进程写入时内容交错)
+func (d *BotDispatcher) writeDeadLetter(l *BotDeadLetter) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	d.dlMu.Lock()
+	defer d.dlMu.Unlock()
+
+	dirname := filepath.Dir(d.deadLetterFile)
+
+	if !IsDir(dirname) {
+		if err = os.MkdirAll(dirname, 0755); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(d.deadLetterFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// LoadBotDeadLetters 读取死信文件。
+//
+// 注意: 飞书签名校验依赖消息类型, 原始消息重新发送时不会重新签名, 开启签名校验的飞书机器人需重新构造消息。
+func LoadBotDeadLetters(filename string) ([]*BotDeadLetter, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var letters []*BotDeadLetter
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		l := &BotDeadLetter{}
+		if err = json.Unmarshal(line, l); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Invalid dead letter. (line: %d)", n))
+		}
+
+		letters = append(letters, l)
+	}
+
+	return letters, scanner.Err()
+}
@@ -0,0 +1,172 @@
+package goutils
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type testDispatchSender struct {
+	mu       sync.Mutex
+	failures map[string][]error
+	sent     []string
+}
+
+func (s *testDispatchSender) Send(v BotMessage) error {
+	msg := v.(*WxWorkTextMessage).Text.Content
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if errs := s.failures[msg]; len(errs) > 0 {
+		s.failures[msg] = errs[1:]
+		return errs[0]
+	}
+
+	s.sent = append(s.sent, msg)
+
+	return nil
+}
+
+func TestBotDispatcher(t *testing.T) {
+	sender := &testDispatchSender{failures: map[string][]error{
+		// 限流及 5xx 错误重试后成功
+		"throttled": {&BotError{Platform: "dingtalk", Code: 410100, Message: "send too fast"}, &HttpError{StatusCode: http.StatusBadGateway}},
+		// 非临时性错误不重试
+		"invalid": {&BotError{Platform: "wxwork", Code: 93000, Message: "invalid webhook url"}},
+		// 超过最大尝试次数
+		"down": {&HttpError{StatusCode: 503}, &HttpError{StatusCode: 503}, &HttpError{StatusCode: 503}},
+	}}
+
+	filename := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	var (
+		mu     sync.Mutex
+		failed []error
+	)
+
+	d := NewBotDispatcher(sender,
+		BotDispatcherOptionWithRetry(&HttpRetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond}),
+		BotDispatcherOptionWithDeadLetterFile(filename),
+		BotDispatcherOptionWithErrorHandler(func(v BotMessage, err error) {
+			mu.Lock()
+			failed = append(failed, err)
+			mu.Unlock()
+		}),
+	)
+
+	for _, msg := range []string{"ok", "throttled", "invalid", "down"} {
+		if err := d.Send(NewWxWorkTextMessage(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Send(NewWxWorkTextMessage("late")); err != ErrBotDispatcherClosed {
+		t.Errorf("expected ErrBotDispatcherClosed, got %v", err)
+	}
+
+	if len(sender.sent) != 2 || sender.sent[0] != "ok" || sender.sent[1] != "throttled" {
+		t.Errorf("unexpected sent messages: %v", sender.sent)
+	}
+
+	if len(failed) != 2 {
+		t.Errorf("unexpected failures: %v", failed)
+	}
+
+	letters, err := LoadBotDeadLetters(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("unexpected dead letters: %+v", letters)
+	}
+
+	msg := &WxWorkTextMessage{}
+	if b, _ := letters[1].Body(); b == nil || letters[1].Sender != "*goutils.testDispatchSender" {
+		t.Errorf("unexpected dead letter: %+v", letters[1])
+	} else if err = json.Unmarshal(b, msg); err != nil || msg.Text.Content != "down" {
+		t.Errorf("unexpected dead letter message: %s", b)
+	}
+}
+
+func TestBotDispatcher_CloseTimeout(t *testing.T) {
+	sender := &testDispatchSender{failures: map[string][]error{
+		"slow": {&HttpError{StatusCode: 503}},
+	}}
+
+	filename := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	d := NewBotDispatcher(sender,
+		BotDispatcherOptionWithQueueSize(1),
+		BotDispatcherOptionWithRetry(&HttpRetryPolicy{MaxAttempts: 3, InitialInterval: time.Hour}),
+		BotDispatcherOptionWithDeadLetterFile(filename),
+	)
+
+	d.Send(NewWxWorkTextMessage("slow"))
+	time.Sleep(20 * time.Millisecond)
+	d.Send(NewWxWorkTextMessage("queued"))
+
+	if err := d.Send(NewWxWorkTextMessage("overflow")); err != ErrBotQueueFull {
+		t.Errorf("expected ErrBotQueueFull, got %v", err)
+	}
+
+	// 阻塞在 SendContext 中的调用方不影响 Close 超时
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- d.SendContext(context.Background(), NewWxWorkTextMessage("blocked"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := d.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	if err := <-blocked; err != ErrBotDispatcherClosed {
+		t.Errorf("expected ErrBotDispatcherClosed, got %v", err)
+	}
+
+	letters, err := LoadBotDeadLetters(filename)
+	if err != nil || len(letters) != 2 {
+		t.Errorf("expected 2 dead letters, got %d (%v)", len(letters), err)
+	}
+}
+
+func TestIsBotRetryableError(t *testing.T) {
+	cases := []struct {
+		err       error
+		retryable bool
+	}{
+		{&BotError{Platform: "wxwork", Code: 45009}, true},
+		{&BotError{Platform: "wxwork", Code: 93000}, false},
+		{&HttpError{StatusCode: 429}, true},
+		{&HttpError{StatusCode: 400}, false},
+		{&url.Error{Op: "Post", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, true},
+		{&url.Error{Op: "Post", URL: "https://example.com", Err: context.DeadlineExceeded}, true},
+		{&url.Error{Op: "Post", URL: "https://example.com", Err: x509.UnknownAuthorityError{}}, false},
+		{&url.Error{Op: "Post", URL: "https://example.com", Err: &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}}, false},
+		{&url.Error{Op: "Post", URL: "ftp://example.com", Err: errors.New("unsupported protocol scheme")}, false},
+	}
+
+	for _, c := range cases {
+		if IsBotRetryableError(c.err) != c.retryable {
+			t.Errorf("Unexpected retryable result. (%v, expected: %v)", c.err, c.retryable)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package goutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	logger "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotificationGrouperClosed 告警分组已关闭。
+var ErrNotificationGrouperClosed = errors.New("The notification grouper is closed.")
+
+// NotificationGrouper 告警去重及分组。(类似 Alertmanager 的分组语义, 在进程内实现)
+//
+// 指纹相同的通知视为同一条告警, 并按 GroupBy 标签分组:
+//   - 新分组等待 GroupWait 后发送首条消息, 期间同组的告警合并发送;
+//   - 发送后 SilenceWindow 内同组新增或恢复的告警仅记录, 窗口结束后合并发送, 重复触发的告警仅计数;
+//   - 告警持续触发且无变化时, 每隔 RepeatInterval 重复发送一次;
+//   - 调用 Resolve 或超过 ResolveTimeout 未再触发时视为已恢复, SendResolved 为 true 时发送恢复消息。
+type NotificationGrouper struct {
+	Sender NotificationSender
+	// 分组标签 (为空时每条告警单独分组)
+	GroupBy []string
+	// 计算告警指纹 (默认值: Notification.Fingerprint, 为空时按标题及标签计算)
+	Fingerprint func(n *Notification) string
+	// 新分组首次发送前的等待时间 (为 0 时立即发送)
+	GroupWait time.Duration
+	// 发送后的静默时间 (NewNotificationGrouper 默认值: 5min)
+	SilenceWindow time.Duration
+	// 重复发送间隔 (NewNotificationGrouper 默认值: 4h, 为 0 时不重复发送)
+	RepeatInterval time.Duration
+	// 超过该时间未再触发视为已恢复 (为 0 时仅通过 Resolve 恢复)
+	ResolveTimeout time.Duration
+	// 是否发送恢复消息？
+	SendResolved bool
+	// 异步发送失败时的回调 (未设置时输出日志)
+	OnError func(err error)
+
+	mu     sync.Mutex
+	groups map[string]*notificationGroup
+	closed bool
+}
+
+type notificationGroup struct {
+	key    string
+	labels map[string]string
+	alerts map[string]*groupedAlert
+	// 按首次触发排序的指纹
+	order   []string
+	created time.Time
+	// 最近一次发送时间 (零值表示尚未发送)
+	sentAt time.Time
+	// 上次发送后是否有新增或恢复的告警？
+	changed bool
+	timer   *time.Timer
+}
+
+type groupedAlert struct {
+	n          *Notification
+	count      int
+	startsAt   time.Time
+	lastSeen   time.Time
+	resolvedAt time.Time
+	resolved   bool
+	// 是否已发送过触发消息？(未发送过的告警恢复时不发送恢复消息)
+	notified bool
+}
+
+// NewNotificationGrouper 创建告警去重及分组。
+func NewNotificationGrouper(sender NotificationSender) *NotificationGrouper {
+	return &NotificationGrouper{
+		Sender:         sender,
+		SilenceWindow:  5 * time.Minute,
+		RepeatInterval: 4 * time.Hour,
+		SendResolved:   true,
+	}
+}
+
+// Fire 触发告警。需要立即发送时同步发送并返回错误, 否则返回 nil, 之后的发送错误通过 OnError 回调。
+func (g *NotificationGrouper) Fire(n *Notification) error {
+	now := time.Now()
+	fp := g.fingerprint(n)
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return ErrNotificationGrouperClosed
+	}
+
+	if g.groups == nil {
+		g.groups = map[string]*notificationGroup{}
+	}
+
+	key, labels := g.groupKey(n, fp)
+
+	grp := g.groups[key]
+	if grp == nil {
+		grp = &notificationGroup{key: key, labels: labels, alerts: map[string]*groupedAlert{}, created: now}
+		g.groups[key] = grp
+	}
+
+	a := grp.alerts[fp]
+	if a == nil {
+		a = &groupedAlert{startsAt: now}
+		grp.alerts[fp] = a
+		grp.order = append(grp.order, fp)
+		grp.changed = true
+	}
+
+	// 恢复消息发送前再次触发, 视为持续触发
+	a.resolved = false
+	a.n = n
+	a.count++
+	a.lastSeen = now
+
+	msgs := g.evaluate(grp, now)
+	g.mu.Unlock()
+
+	return g.send(msgs)
+}
+
+// Resolve 恢复告警 (按指纹匹配)。需要立即发送恢复消息时同步发送并返回错误。
+func (g *NotificationGrouper) Resolve(n *Notification) error {
+	now := time.Now()
+	fp := g.fingerprint(n)
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return ErrNotificationGrouperClosed
+	}
+
+	key, _ := g.groupKey(n, fp)
+
+	grp := g.groups[key]
+	if grp == nil || grp.alerts[fp] == nil || grp.alerts[fp].resolved {
+		g.mu.Unlock()
+		return nil
+	}
+
+	grp.resolve(grp.alerts[fp], now)
+
+	msgs := g.evaluate(grp, now)
+	g.mu.Unlock()
+
+	return g.send(msgs)
+}
+
+// Firing 返回触发中的告警数。
+func (g *NotificationGrouper) Firing() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	count := 0
+	for _, grp := range g.groups {
+		for _, a := range grp.alerts {
+			if !a.resolved {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// Close 停止所有定时器, 不再发送消息。
+func (g *NotificationGrouper) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed = true
+
+	for _, grp := range g.groups {
+		if grp.timer != nil {
+			grp.timer.Stop()
+		}
+	}
+	g.groups = nil
+}
+
+func (g *NotificationGrouper) fingerprint(n *Notification) string {
+	if g.Fingerprint != nil {
+		return g.Fingerprint(n)
+	}
+
+	return n.fingerprint()
+}
+
+// fingerprint 返回告警指纹。(Fingerprint 为空时按标题及标签计算)
+func (n *Notification) fingerprint() string {
+	if n.Fingerprint != "" {
+		return n.Fingerprint
+	}
+
+	h := sha256.New()
+	h.Write([]byte(n.Title))
+
+	for _, k := range sortedLabelKeys(n.Labels) {
+		h.Write([]byte{0})
+		h.Write([]byte(k + "=" + n.Labels[k]))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// groupKey 返回分组键及分组标签。
+func (g *NotificationGrouper) groupKey(n *Notification, fp string) (string, map[string]string) {
+	if len(g.GroupBy) == 0 {
+		return "fingerprint:" + fp, n.Labels
+	}
+
+	labels := map[string]string{}
+	parts := make([]string, len(g.GroupBy))
+
+	for i, k := range g.GroupBy {
+		labels[k] = n.Labels[k]
+		parts[i] = k + "=" + n.Labels[k]
+	}
+
+	return strings.Join(parts, "\x00"), labels
+}
+
+func (grp *notificationGroup) resolve(a *groupedAlert, now time.Time) {
+	a.resolved = true
+	a.resolvedAt = now
+
+	if a.notified {
+		grp.changed = true
+	}
+}
+
+// evaluate 检查分组是否需要发送并重新设置定时器, 返回待发送的消息。(需持有锁)
+func (g *NotificationGrouper) evaluate(grp *notificationGroup, now time.Time) []*Notification {
+	if g.ResolveTimeout > 0 {
+		for _, a := range grp.alerts {
+			if !a.resolved && now.Sub(a.lastSeen) >= g.ResolveTimeout {
+				grp.resolve(a, now)
+			}
+		}
+	}
+
+	// 未发送过的告警恢复时直接移除
+	grp.remove(func(a *groupedAlert) bool {
+		return a.resolved && !a.notified
+	})
+
+	var firing, resolved []*groupedAlert
+
+	for _, fp := range grp.order {
+		if a := grp.alerts[fp]; a.resolved {
+			resolved = append(resolved, a)
+		} else {
+			firing = append(firing, a)
+		}
+	}
+
+	var due bool
+
+	switch {
+	case grp.sentAt.IsZero():
+		due = len(firing) > 0 && !now.Before(grp.created.Add(g.GroupWait))
+	case grp.changed:
+		due = !now.Before(grp.sentAt.Add(g.SilenceWindow))
+	case len(firing) > 0 && g.RepeatInterval > 0:
+		due = !now.Before(grp.sentAt.Add(g.RepeatInterval))
+	}
+
+	var msgs []*Notification
+
+	if due {
+		if len(firing) > 0 {
+			msgs = append(msgs, g.firingMessage(grp, firing))
+		}
+
+		if len(resolved) > 0 && g.SendResolved {
+			msgs = append(msgs, g.resolvedMessage(grp, resolved))
+		}
+
+		for _, a := range firing {
+			a.notified = true
+		}
+
+		grp.remove(func(a *groupedAlert) bool {
+			return a.resolved
+		})
+
+		grp.sentAt = now
+		grp.changed = false
+	}
+
+	if grp.timer != nil {
+		grp.timer.Stop()
+		grp.timer = nil
+	}
+
+	if len(grp.alerts) == 0 {
+		delete(g.groups, grp.key)
+		return msgs
+	}
+
+	if next := g.next(grp); !next.IsZero() {
+		key := grp.key
+		grp.timer = time.AfterFunc(next.Sub(now), func() {
+			g.tick(key)
+		})
+	}
+
+	return msgs
+}
+
+// next 返回分组下一次需要检查的时间。
+func (g *NotificationGrouper) next(grp *notificationGroup) time.Time {
+	var next time.Time
+
+	set := func(t time.Time) {
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	switch {
+	case grp.sentAt.IsZero():
+		set(grp.created.Add(g.GroupWait))
+	case grp.changed:
+		set(grp.sentAt.Add(g.SilenceWindow))
+	case g.RepeatInterval > 0:
+		set(grp.sentAt.Add(g.RepeatInterval))
+	}
+
+	if g.ResolveTimeout > 0 {
+		for _, a := range grp.alerts {
+			if !a.resolved {
+				set(a.lastSeen.Add(g.ResolveTimeout))
+			}
+		}
+	}
+
+	return next
+}
+
+func (grp *notificationGroup) remove(fn func(a *groupedAlert) bool) {
+	order := grp.order[:0]
+
+	for _, fp := range grp.order {
+		if fn(grp.alerts[fp]) {
+			delete(grp.alerts, fp)
+		} else {
+			order = append(order, fp)
+		}
+	}
+
+	grp.order = order
+}
+
+func (g *NotificationGrouper) tick(key string) {
+	g.mu.Lock()
+	grp := g.groups[key]
+	if g.closed || grp == nil {
+		g.mu.Unlock()
+		return
+	}
+
+	msgs := g.evaluate(grp, time.Now())
+	g.mu.Unlock()
+
+	if err := g.send(msgs); err != nil {
+		if g.OnError != nil {
+			g.OnError(err)
+		} else {
+			logger.Errorf("Failed to send grouped notification. (error: %v)", err)
+		}
+	}
+}
+
+func (g *NotificationGrouper) send(msgs []*Notification) error {
+	for _, n := range msgs {
+		if err := SendNotification(g.Sender, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// groupTitle 返回分组标签组成的标题前缀。
+func (grp *notificationGroup) groupTitle() string {
+	var parts []string
+
+	for _, k := range sortedLabelKeys(grp.labels) {
+		parts = append(parts, k+"="+grp.labels[k])
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return "[" + strings.Join(parts, ", ") + "] "
+}
+
+// firingMessage 生成触发消息。单条告警时使用原通知 (重复触发时附加触发次数及首次触发时间), 多条告警时合并为列表。
+func (g *NotificationGrouper) firingMessage(grp *notificationGroup, alerts []*groupedAlert) *Notification {
+	if len(alerts) == 1 {
+		a := alerts[0]
+		n := *a.n
+
+		if a.count > 1 {
+			n.Fields = append(append([]NotificationField(nil), a.n.Fields...),
+				NotificationField{Name: "触发次数", Value: fmt.Sprint(a.count), Short: true},
+				NotificationField{Name: "首次触发", Value: a.startsAt.Format("2006-01-02 15:04:05"), Short: true},
+			)
+		}
+
+		return &n
+	}
+
+	n := &Notification{
+		Title:  fmt.Sprintf("%s%d 条告警", grp.groupTitle(), len(alerts)),
+		Labels: grp.labels,
+	}
+
+	mentions := map[NotificationMention]bool{}
+	lines := make([]string, len(alerts))
+
+	for i, a := range alerts {
+		if a.n.Severity > n.Severity {
+			n.Severity = a.n.Severity
+		}
+
+		n.MentionAll = n.MentionAll || a.n.MentionAll
+
+		for _, m := range a.n.Mentions {
+			if !mentions[m] {
+				mentions[m] = true
+				n.Mentions = append(n.Mentions, m)
+			}
+		}
+
+		lines[i] = fmt.Sprintf("- **%s**", a.n.Title)
+		if summary := firstLine(a.n.Markdown); summary != "" {
+			lines[i] += " " + summary
+		}
+		if a.count > 1 {
+			lines[i] += fmt.Sprintf(" (触发 %d 次)", a.count)
+		}
+	}
+
+	n.Markdown = strings.Join(lines, "\n")
+
+	return n
+}
+
+// resolvedMessage 生成恢复消息。
+func (g *NotificationGrouper) resolvedMessage(grp *notificationGroup, alerts []*groupedAlert) *Notification {
+	if len(alerts) == 1 {
+		a := alerts[0]
+
+		return &Notification{
+			Title:    "[已恢复] " + a.n.Title,
+			Labels:   a.n.Labels,
+			Markdown: fmt.Sprintf("持续时间: %s", a.resolvedAt.Sub(a.startsAt).Round(time.Second)),
+		}
+	}
+
+	lines := make([]string, len(alerts))
+	for i, a := range alerts {
+		lines[i] = fmt.Sprintf("- **%s** 持续 %s", a.n.Title, a.resolvedAt.Sub(a.startsAt).Round(time.Second))
+	}
+
+	return &Notification{
+		Title:    fmt.Sprintf("%s%d 条告警已恢复", grp.groupTitle(), len(alerts)),
+		Labels:   grp.labels,
+		Markdown: strings.Join(lines, "\n"),
+	}
+}
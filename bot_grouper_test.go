@@ -0,0 +1,118 @@
+package goutils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotificationGrouper_Dedup(t *testing.T) {
+	sender := &testDigestSender{}
+	g := NewNotificationGrouper(sender)
+	g.SilenceWindow = 50 * time.Millisecond
+	g.RepeatInterval = 150 * time.Millisecond
+	defer g.Close()
+
+	n := &Notification{Title: "job failed", Labels: map[string]string{"job": "sync"}}
+
+	// 首次立即发送, 重复触发仅计数
+	for i := 0; i < 10; i++ {
+		if err := g.Fire(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if sent := sender.notifications(); len(sent) != 1 || sent[0].Title != "job failed" || len(sent[0].Fields) != 0 {
+		t.Fatalf("unexpected notifications: %d", len(sent))
+	}
+
+	// 超过重复发送间隔后附加触发次数
+	time.Sleep(200 * time.Millisecond)
+
+	sent := sender.notifications()
+	if len(sent) != 2 || len(sent[1].Fields) != 2 || sent[1].Fields[0].Value != "10" {
+		t.Fatalf("unexpected notifications: %d", len(sent))
+	}
+
+	// 恢复消息在静默时间结束后发送 (距上次发送已超过静默时间, 立即发送)
+	time.Sleep(g.SilenceWindow)
+
+	if err := g.Resolve(&Notification{Title: "job failed", Labels: map[string]string{"job": "sync"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent = sender.notifications()
+	if len(sent) != 3 || sent[2].Title != "[已恢复] job failed" || !strings.HasPrefix(sent[2].Markdown, "持续时间: ") {
+		t.Fatalf("unexpected notifications: %d", len(sent))
+	}
+	if g.Firing() != 0 || len(g.groups) != 0 {
+		t.Errorf("expected empty groups")
+	}
+}
+
+func TestNotificationGrouper_Group(t *testing.T) {
+	sender := &testDigestSender{}
+	g := NewNotificationGrouper(sender)
+	g.GroupBy = []string{"team"}
+	g.GroupWait = 50 * time.Millisecond
+	g.SilenceWindow = 100 * time.Millisecond
+	defer g.Close()
+
+	g.Fire(&Notification{Title: "disk full", Severity: SeverityWarning, Labels: map[string]string{"team": "infra", "host": "a"}})
+	g.Fire(&Notification{Title: "disk full", Severity: SeverityCritical, Labels: map[string]string{"team": "infra", "host": "b"}})
+	g.Fire(&Notification{Title: "disk full", Labels: map[string]string{"team": "infra", "host": "b"}})
+
+	if len(sender.notifications()) != 0 {
+		t.Fatalf("expected group wait")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	sent := sender.notifications()
+	if len(sent) != 1 || sent[0].Title != "[team=infra] 2 条告警" || sent[0].Severity != SeverityWarning {
+		t.Fatalf("unexpected notifications: %+v", sent)
+	}
+	if sent[0].Markdown != "- **disk full**\n- **disk full** (触发 2 次)" {
+		t.Errorf("unexpected markdown: %q", sent[0].Markdown)
+	}
+
+	// 静默时间内的变化合并发送, 未发送过的告警恢复时不发送恢复消息
+	g.Fire(&Notification{Title: "cpu high", Labels: map[string]string{"team": "infra"}})
+	g.Resolve(&Notification{Title: "cpu high", Labels: map[string]string{"team": "infra"}})
+	g.Resolve(&Notification{Title: "disk full", Labels: map[string]string{"team": "infra", "host": "a"}})
+
+	if len(sender.notifications()) != 1 {
+		t.Fatalf("expected silence window")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	sent = sender.notifications()
+	if len(sent) != 3 || sent[1].Title != "disk full" || sent[2].Title != "[已恢复] disk full" {
+		t.Fatalf("unexpected notifications: %d", len(sent))
+	}
+	if g.Firing() != 1 {
+		t.Errorf("unexpected firing: %d", g.Firing())
+	}
+}
+
+func TestNotificationGrouper_ResolveTimeout(t *testing.T) {
+	sender := &testDigestSender{}
+	g := NewNotificationGrouper(sender)
+	g.SilenceWindow = 0
+	g.ResolveTimeout = 50 * time.Millisecond
+	defer g.Close()
+
+	g.Fire(&Notification{Title: "job failed", Fingerprint: "job-1"})
+	time.Sleep(100 * time.Millisecond)
+
+	sent := sender.notifications()
+	if len(sent) != 2 || sent[1].Title != "[已恢复] job failed" || g.Firing() != 0 {
+		t.Fatalf("unexpected notifications: %d", len(sent))
+	}
+
+	g.Close()
+	if err := g.Fire(&Notification{Title: "job failed"}); err != ErrNotificationGrouperClosed {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package goutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Notification 跨平台通知消息。由各平台 BotSender 渲染为对应的卡片/Markdown 消息, 以便同一条告警以一致的格式发送至飞书、钉钉及企业微信。
+//
+// Markdown 正文建议仅使用各平台通用的语法 (粗体、链接、列表及换行)。
+type Notification struct {
+	// 严重级别 (用于 Notifier 路由及飞书卡片标题栏颜色)
+	Severity NotificationSeverity
+	// 标签 (用于 Notifier 路由, 如: team=infra)
+	Labels map[string]string
+	// 告警指纹 (用于 NotificationGrouper 去重, 为空时按标题及标签计算)
+	Fingerprint string
+	// 标题
+	Title string
+	// 正文 (Markdown)
+	Markdown string
+	// 键值对字段 (如: 服务、环境、发生时间)
+	Fields []NotificationField
+	// 跳转按钮
+	Buttons []NotificationButton
+	// @ 提醒的用户
+	Mentions []NotificationMention
+	// 是否 @ 所有人？
+	MentionAll bool
+	// 图片
+	Images []NotificationImage
+}
+
+// NotificationSeverity 通知严重级别。
+type NotificationSeverity int
+
+const (
+	SeverityInfo NotificationSeverity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s NotificationSeverity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// NotificationField 键值对字段。
+type NotificationField struct {
+	Name  string
+	Value string
+	// 是否为短字段？(飞书卡片中并排显示)
+	Short bool
+}
+
+// NotificationButton 跳转按钮。
+type NotificationButton struct {
+	Text string
+	URL  string
+}
+
+// NotificationMention @ 提醒的用户。各平台的用户标识不同, 未设置对应平台标识的用户在该平台不会被提醒。
+type NotificationMention struct {
+	// 显示名称
+	Name string
+	// 手机号 (钉钉)
+	Mobile string
+	// 飞书 open_id 或 user_id
+	FeishuID string
+	// 钉钉 userId
+	DingtalkID string
+	// 企业微信 userid
+	WxWorkID string
+}
+
+// NotificationImage 图片。
+type NotificationImage struct {
+	// 图片地址 (钉钉以图片显示, 企业微信以链接显示)
+	URL string
+	// 飞书图片 key (通过 FeishuBotSender.UploadImage 获取, 未设置时以链接显示)
+	FeishuImageKey string
+	Alt            string
+}
+
+// NewNotification 创建跨平台通知消息。
+func NewNotification(title, markdown string) *Notification {
+	return &Notification{Title: title, Markdown: markdown}
+}
+
+// AddField 添加键值对字段。
+func (n *Notification) AddField(name, value string, short bool) *Notification {
+	n.Fields = append(n.Fields, NotificationField{Name: name, Value: value, Short: short})
+
+	return n
+}
+
+// AddButton 添加跳转按钮。
+func (n *Notification) AddButton(text, url string) *Notification {
+	n.Buttons = append(n.Buttons, NotificationButton{Text: text, URL: url})
+
+	return n
+}
+
+// AddMention 添加 @ 提醒的用户。
+func (n *Notification) AddMention(m NotificationMention) *Notification {
+	n.Mentions = append(n.Mentions, m)
+
+	return n
+}
+
+// AddImage 添加图片。
+func (n *Notification) AddImage(img NotificationImage) *Notification {
+	n.Images = append(n.Images, img)
+
+	return n
+}
+
+// NotificationRenderer 将跨平台通知渲染为平台消息。
+type NotificationRenderer interface {
+	Render(n *Notification) (BotMessage, error)
+}
+
+// NotificationSender 支持发送跨平台通知的 BotSender。
+type NotificationSender interface {
+	BotSender
+	NotificationRenderer
+}
+
+// SendNotification 渲染并发送跨平台通知。
+func SendNotification(s NotificationSender, n *Notification) error {
+	msg, err := s.Render(n)
+	if err != nil {
+		return err
+	}
+
+	return s.Send(msg)
+}
+
+// fieldsMarkdown 将字段渲染为 "**名称**: 值" 形式的行。
+func (n *Notification) fieldsMarkdown() string {
+	lines := make([]string, len(n.Fields))
+	for i, f := range n.Fields {
+		lines[i] = fmt.Sprintf("**%s**: %s", f.Name, f.Value)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Render 渲染为飞书卡片消息。
+func (s *FeishuBotSender) Render(n *Notification) (BotMessage, error) {
+	msg := NewFeishuCardMessage(n.Title)
+
+	switch n.Severity {
+	case SeverityWarning:
+		msg.Card.Header.Template = "orange"
+	case SeverityCritical:
+		msg.Card.Header.Template = "red"
+	}
+
+	if n.Markdown != "" {
+		msg.AddLineContent(n.Markdown)
+	}
+
+	if len(n.Fields) > 0 {
+		elem := feishuCardMessageElement{Tag: "div"}
+
+		for _, f := range n.Fields {
+			elem.Fields = append(elem.Fields, feishuCardMessageField{
+				IsShort: f.Short,
+				Text:    feishuCardText{Tag: "lark_md", Content: fmt.Sprintf("**%s**\n%s", f.Name, f.Value)},
+			})
+		}
+
+		msg.Card.Elements = append(msg.Card.Elements, elem)
+	}
+
+	var links []string
+
+	for _, img := range n.Images {
+		if img.FeishuImageKey != "" {
+			msg.AddImage(img.FeishuImageKey, img.Alt)
+		} else if img.URL != "" {
+			links = append(links, fmt.Sprintf("[%s](%s)", imageAlt(img), img.URL))
+		}
+	}
+
+	if len(links) > 0 {
+		msg.AddLineContent(strings.Join(links, "\n"))
+	}
+
+	var mentions []string
+
+	if n.MentionAll {
+		mentions = append(mentions, "<at id=all></at>")
+	}
+
+	for _, m := range n.Mentions {
+		if m.FeishuID != "" {
+			mentions = append(mentions, fmt.Sprintf("<at id=%s></at>", m.FeishuID))
+		}
+	}
+
+	if len(mentions) > 0 {
+		msg.AddLineContent(strings.Join(mentions, " "))
+	}
+
+	if len(n.Buttons) > 0 {
+		elem := feishuCardMessageElement{Tag: "action"}
+
+		for _, b := range n.Buttons {
+			action := feishuCardMessageElementAction{Tag: "button", Type: "default", URL: b.URL}
+			action.Text.Tag = "lark_md"
+			action.Text.Content = b.Text
+
+			elem.Actions = append(elem.Actions, action)
+		}
+
+		msg.Card.Elements = append(msg.Card.Elements, elem)
+	}
+
+	return msg, nil
+}
+
+// Render 渲染为钉钉消息。包含按钮且无需 @ 提醒时使用 ActionCard 消息, 否则使用 Markdown 消息 (按钮以链接显示)。
+func (s *DingtalkBotSender) Render(n *Notification) (BotMessage, error) {
+	var parts []string
+
+	if n.Title != "" {
+		parts = append(parts, "#### "+n.Title)
+	}
+
+	if n.Markdown != "" {
+		parts = append(parts, n.Markdown)
+	}
+
+	if len(n.Fields) > 0 {
+		// 钉钉 Markdown 需要两个换行符才能换行
+		parts = append(parts, strings.ReplaceAll(n.fieldsMarkdown(), "\n", "\n\n"))
+	}
+
+	for _, img := range n.Images {
+		if img.URL != "" {
+			parts = append(parts, fmt.Sprintf("![%s](%s)", imageAlt(img), img.URL))
+		}
+	}
+
+	var (
+		mobiles, userIds, mentions []string
+	)
+
+	for _, m := range n.Mentions {
+		if m.Mobile != "" {
+			mobiles = append(mobiles, m.Mobile)
+			mentions = append(mentions, "@"+m.Mobile)
+		} else if m.DingtalkID != "" {
+			userIds = append(userIds, m.DingtalkID)
+			mentions = append(mentions, "@"+m.DingtalkID)
+		}
+	}
+
+	if len(n.Buttons) > 0 && len(mentions) == 0 && !n.MentionAll {
+		msg := NewDingtalkActionCardMessage(n.Title, strings.Join(parts, "\n\n"))
+		for _, b := range n.Buttons {
+			msg.AddButton(b.Text, b.URL)
+		}
+
+		return msg, nil
+	}
+
+	if len(n.Buttons) > 0 {
+		var links []string
+		for _, b := range n.Buttons {
+			links = append(links, fmt.Sprintf("[%s](%s)", b.Text, b.URL))
+		}
+		parts = append(parts, strings.Join(links, " | "))
+	}
+
+	// 被 @ 的用户需出现在正文中才会高亮显示
+	if len(mentions) > 0 {
+		parts = append(parts, strings.Join(mentions, " "))
+	}
+
+	msg := NewDingtalkMarkdownMessage(n.Title, strings.Join(parts, "\n\n"), n.MentionAll)
+	if !n.MentionAll {
+		msg.At.AtMobiles = mobiles
+		msg.At.AtUserIds = userIds
+	}
+
+	return msg, nil
+}
+
+// Render 渲染为企业微信 Markdown 消息。(企业微信 Markdown 消息不支持图片及 @ 所有人, 图片以链接显示, 仅能通过 userid @ 提醒)
+func (s *WxWorkBotSender) Render(n *Notification) (BotMessage, error) {
+	var parts []string
+
+	if n.Title != "" {
+		parts = append(parts, "**"+n.Title+"**")
+	}
+
+	if n.Markdown != "" {
+		parts = append(parts, n.Markdown)
+	}
+
+	if len(n.Fields) > 0 {
+		parts = append(parts, n.fieldsMarkdown())
+	}
+
+	var links []string
+
+	for _, img := range n.Images {
+		if img.URL != "" {
+			links = append(links, fmt.Sprintf("[%s](%s)", imageAlt(img), img.URL))
+		}
+	}
+
+	for _, b := range n.Buttons {
+		links = append(links, fmt.Sprintf("[%s](%s)", b.Text, b.URL))
+	}
+
+	if len(links) > 0 {
+		parts = append(parts, strings.Join(links, "\n"))
+	}
+
+	var mentions []string
+
+	for _, m := range n.Mentions {
+		if m.WxWorkID != "" {
+			mentions = append(mentions, fmt.Sprintf("<@%s>", m.WxWorkID))
+		}
+	}
+
+	if len(mentions) > 0 {
+		parts = append(parts, strings.Join(mentions, " "))
+	}
+
+	return NewWxWorkMarkdownMessage(strings.Join(parts, "\n")), nil
+}
+
+func imageAlt(img NotificationImage) string {
+	if img.Alt != "" {
+		return img.Alt
+	}
+
+	return "图片"
+}
@@ -0,0 +1,94 @@
+package goutils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newTestNotification() *Notification {
+	n := NewNotification("CPU 使用率过高", "服务器 **web-01** CPU 使用率超过 90%")
+	n.AddField("环境", "production", true).AddField("数值", "95%", true)
+	n.AddButton("查看详情", "https://example.com/alerts/1")
+	n.AddImage(NotificationImage{URL: "https://example.com/chart.png", FeishuImageKey: "img_v2_xxx", Alt: "趋势图"})
+
+	return n
+}
+
+func renderJSON(t *testing.T, r NotificationRenderer, n *Notification) (BotMessage, string) {
+	msg, err := r.Render(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := msg.Body()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msg, string(b)
+}
+
+func TestFeishuBotSender_Render(t *testing.T) {
+	n := newTestNotification()
+	n.AddMention(NotificationMention{Name: "张三", FeishuID: "ou_123", Mobile: "13800000000"})
+
+	msg, body := renderJSON(t, &FeishuBotSender{}, n)
+	if _, ok := msg.(*FeishuCardMessage); !ok {
+		t.Fatalf("unexpected message type: %T", msg)
+	}
+
+	for _, s := range []string{`"CPU 使用率过高"`, `"is_short":true`, `**环境**\nproduction`, `"img_key":"img_v2_xxx"`, `\u003cat id=ou_123\u003e`, `"url":"https://example.com/alerts/1"`} {
+		if !strings.Contains(body, s) {
+			t.Errorf("body does not contain %s: %s", s, body)
+		}
+	}
+}
+
+func TestDingtalkBotSender_Render(t *testing.T) {
+	n := newTestNotification()
+
+	msg, body := renderJSON(t, &DingtalkBotSender{}, n)
+	if _, ok := msg.(*DingtalkActionCardMessage); !ok {
+		t.Fatalf("expected action card, got %T", msg)
+	}
+	if !strings.Contains(body, `![趋势图](https://example.com/chart.png)`) || !strings.Contains(body, `"actionURL":"https://example.com/alerts/1"`) {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	n.AddMention(NotificationMention{Mobile: "13800000000"})
+	n.AddMention(NotificationMention{DingtalkID: "user1"})
+
+	msg, body = renderJSON(t, &DingtalkBotSender{}, n)
+	md, ok := msg.(*DingtalkMarkdownMessage)
+	if !ok {
+		t.Fatalf("expected markdown, got %T", msg)
+	}
+	if strings.Join(md.At.AtMobiles, ",") != "13800000000" || strings.Join(md.At.AtUserIds, ",") != "user1" {
+		t.Errorf("unexpected at: %+v", md.At)
+	}
+	if !strings.Contains(md.Markdown.Text, "@13800000000 @user1") || !strings.Contains(md.Markdown.Text, "[查看详情](https://example.com/alerts/1)") {
+		t.Errorf("unexpected text: %s", md.Markdown.Text)
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil || v["msgtype"] != "markdown" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestWxWorkBotSender_Render(t *testing.T) {
+	n := newTestNotification()
+	n.AddMention(NotificationMention{WxWorkID: "zhangsan"})
+
+	msg, _ := renderJSON(t, &WxWorkBotSender{}, n)
+	md, ok := msg.(*WxWorkMarkdownMessage)
+	if !ok {
+		t.Fatalf("expected markdown, got %T", msg)
+	}
+
+	want := "**CPU 使用率过高**\n服务器 **web-01** CPU 使用率超过 90%\n**环境**: production\n**数值**: 95%\n[趋势图](https://example.com/chart.png)\n[查看详情](https://example.com/alerts/1)\n<@zhangsan>"
+	if md.Markdown.Content != want {
+		t.Errorf("unexpected content:\n%s", md.Markdown.Content)
+	}
+}
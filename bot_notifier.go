@@ -0,0 +1,285 @@
+package goutils
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotifierChannel 通知渠道。
+type NotifierChannel struct {
+	// 渠道名称 (用于路由及结果)
+	Name string
+	// 发送器 (需实现 NotificationRenderer, 如 FeishuBotSender、DingtalkBotSender、WxWorkBotSender)
+	Sender BotSender
+}
+
+// NotifierRoute 路由规则。Severities 及 Labels 均为空时匹配所有通知。
+type NotifierRoute struct {
+	// 匹配的严重级别 (为空时不限制)
+	Severities []NotificationSeverity
+	// 匹配的最低严重级别 (Severities 为空时生效)
+	MinSeverity NotificationSeverity
+	// 需全部匹配的标签 (值为 "*" 时仅要求标签存在)
+	Labels map[string]string
+	// 发送的渠道名称 (为空时发送至所有渠道)
+	Channels []string
+}
+
+func (r *NotifierRoute) match(n *Notification) bool {
+	if len(r.Severities) > 0 {
+		ok := false
+		for _, s := range r.Severities {
+			if s == n.Severity {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	} else if n.Severity < r.MinSeverity {
+		return false
+	}
+
+	for k, v := range r.Labels {
+		actual, ok := n.Labels[k]
+		if !ok || v != "*" && v != actual {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Notifier 多渠道通知。将同一条通知并发发送至多个渠道, 单个渠道失败不影响其它渠道。
+//
+// 按顺序匹配 Routes, 使用第一条匹配的规则选择渠道; 均不匹配时发送至 DefaultChannels (为空时发送至所有渠道)。
+type Notifier struct {
+	Channels []*NotifierChannel
+	Routes   []*NotifierRoute
+	// 未匹配任何路由规则时发送的渠道名称
+	DefaultChannels []string
+}
+
+// NewNotifier 创建多渠道通知。
+func NewNotifier(channels ...*NotifierChannel) *Notifier {
+	return &Notifier{Channels: channels}
+}
+
+// AddChannel 添加通知渠道。
+func (n *Notifier) AddChannel(name string, sender BotSender) *Notifier {
+	n.Channels = append(n.Channels, &NotifierChannel{Name: name, Sender: sender})
+
+	return n
+}
+
+// AddRoute 添加路由规则。
+func (n *Notifier) AddRoute(route *NotifierRoute) *Notifier {
+	n.Routes = append(n.Routes, route)
+
+	return n
+}
+
+// NotifierChannelResult 单个渠道的发送结果。
+type NotifierChannelResult struct {
+	Channel  string
+	Err      error
+	Duration time.Duration
+}
+
+// NotifierResult 发送结果。
+type NotifierResult struct {
+	Results []*NotifierChannelResult
+}
+
+// Failed 返回发送失败的渠道结果。
+func (r *NotifierResult) Failed() []*NotifierChannelResult {
+	var failed []*NotifierChannelResult
+
+	for _, v := range r.Results {
+		if v.Err != nil {
+			failed = append(failed, v)
+		}
+	}
+
+	return failed
+}
+
+// Err 返回合并后的错误 (全部成功时返回 nil)。
+func (r *NotifierResult) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(failed))
+	for i, v := range failed {
+		messages[i] = fmt.Sprintf("%s: %v", v.Channel, v.Err)
+	}
+
+	return errors.New(fmt.Sprintf("Failed to send notification to %d of %d channels. (%s)", len(failed), len(r.Results), strings.Join(messages, "; ")))
+}
+
+// Validate 检查路由规则及 DefaultChannels 引用的渠道是否均已添加。
+func (n *Notifier) Validate() error {
+	var missing []string
+
+	for _, route := range n.Routes {
+		missing = append(missing, n.missingChannels(route.Channels)...)
+	}
+
+	missing = append(missing, n.missingChannels(n.DefaultChannels)...)
+
+	if len(missing) > 0 {
+		return errors.New(fmt.Sprintf("Unknown notifier channels. (%s)", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// Route 返回通知匹配的渠道。(忽略未添加的渠道名称, Notify 对其返回错误)
+func (n *Notifier) Route(msg *Notification) []*NotifierChannel {
+	channels, _ := n.route(msg)
+
+	return channels
+}
+
+// route 返回通知匹配的渠道及未添加的渠道名称。
+func (n *Notifier) route(msg *Notification) ([]*NotifierChannel, []string) {
+	names := n.DefaultChannels
+
+	for _, route := range n.Routes {
+		if route.match(msg) {
+			names = route.Channels
+			break
+		}
+	}
+
+	if len(names) == 0 {
+		return n.Channels, nil
+	}
+
+	var channels []*NotifierChannel
+
+	for _, ch := range n.Channels {
+		for _, name := range names {
+			if ch.Name == name {
+				channels = append(channels, ch)
+				break
+			}
+		}
+	}
+
+	return channels, n.missingChannels(names)
+}
+
+func (n *Notifier) missingChannels(names []string) []string {
+	var missing []string
+
+	for _, name := range names {
+		found := false
+		for _, ch := range n.Channels {
+			if ch.Name == name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// Notify 将通知并发发送至匹配的渠道, 返回每个渠道的发送结果。ctx 取消时未完成的渠道返回 ctx.Err()。
+// 路由规则引用了未添加的渠道时, 该渠道的结果返回错误。
+func (n *Notifier) Notify(ctx context.Context, msg *Notification) *NotifierResult {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	channels, missing := n.route(msg)
+	result := &NotifierResult{Results: make([]*NotifierChannelResult, len(channels), len(channels)+len(missing))}
+
+	for _, name := range missing {
+		result.Results = append(result.Results, &NotifierChannelResult{
+			Channel: name,
+			Err:     errors.New(fmt.Sprintf("The notifier channel is not registered. (%s)", name)),
+		})
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		// 已写入结果的渠道 (ctx 取消后不再写入, 避免与调用方读取结果竞争)
+		finished = make([]bool, len(channels))
+	)
+
+	for i, ch := range channels {
+		result.Results[i] = &NotifierChannelResult{Channel: ch.Name}
+
+		wg.Add(1)
+
+		go func(i int, ch *NotifierChannel) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := sendChannel(ctx, ch, msg)
+
+			mu.Lock()
+			if !finished[i] {
+				finished[i] = true
+				result.Results[i].Err = err
+				result.Results[i].Duration = time.Since(start)
+			}
+			mu.Unlock()
+		}(i, ch)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// 发送器不支持取消, 不再等待未完成的渠道
+		mu.Lock()
+		for i, v := range result.Results[:len(channels)] {
+			if !finished[i] {
+				finished[i] = true
+				v.Err = ctx.Err()
+			}
+		}
+		mu.Unlock()
+	}
+
+	return result
+}
+
+func sendChannel(ctx context.Context, ch *NotifierChannel, msg *Notification) error {
+	r, ok := ch.Sender.(NotificationRenderer)
+	if !ok {
+		return errors.New(fmt.Sprintf("The sender does not support notification rendering. (%T)", ch.Sender))
+	}
+
+	m, err := r.Render(msg)
+	if err != nil {
+		return err
+	}
+
+	if s, ok := ch.Sender.(BotContextSender); ok {
+		return s.SendContext(ctx, m)
+	}
+
+	return ch.Sender.Send(m)
+}
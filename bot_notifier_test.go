@@ -0,0 +1,142 @@
+package goutils
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testNotifierSender struct {
+	mu    sync.Mutex
+	sent  []BotMessage
+	err   error
+	delay time.Duration
+}
+
+func (s *testNotifierSender) Send(v BotMessage) error {
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, v)
+
+	return s.err
+}
+
+func (s *testNotifierSender) Render(n *Notification) (BotMessage, error) {
+	return NewWxWorkMarkdownMessage(n.Title), nil
+}
+
+func (s *testNotifierSender) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sent)
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	feishu, dingtalk, wxwork := &testNotifierSender{}, &testNotifierSender{err: errors.New("boom")}, &testNotifierSender{}
+
+	notifier := NewNotifier().
+		AddChannel("feishu", feishu).
+		AddChannel("dingtalk", dingtalk).
+		AddChannel("wxwork", wxwork).
+		AddRoute(&NotifierRoute{MinSeverity: SeverityCritical}).
+		AddRoute(&NotifierRoute{Labels: map[string]string{"team": "infra"}, Channels: []string{"dingtalk", "wxwork"}})
+	notifier.DefaultChannels = []string{"feishu"}
+
+	// critical 发送至所有渠道, 单个渠道失败不影响其它渠道
+	result := notifier.Notify(context.Background(), &Notification{Severity: SeverityCritical, Title: "down"})
+	if len(result.Results) != 3 || len(result.Failed()) != 1 || result.Failed()[0].Channel != "dingtalk" {
+		t.Errorf("unexpected results: %+v", result.Results)
+	}
+	if err := result.Err(); err == nil || !strings.Contains(err.Error(), "dingtalk: boom") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if feishu.count() != 1 || wxwork.count() != 1 {
+		t.Error("expected other channels to receive the message")
+	}
+
+	// 按标签路由
+	result = notifier.Notify(context.Background(), &Notification{Title: "disk", Labels: map[string]string{"team": "infra"}})
+	if len(result.Results) != 2 || result.Results[0].Channel != "dingtalk" || result.Results[1].Channel != "wxwork" {
+		t.Errorf("unexpected routing: %+v", result.Results)
+	}
+
+	// 默认渠道
+	result = notifier.Notify(context.Background(), &Notification{Title: "info"})
+	if len(result.Results) != 1 || result.Results[0].Channel != "feishu" || result.Err() != nil {
+		t.Errorf("unexpected routing: %+v", result.Results)
+	}
+}
+
+func TestNotifier_Cancel(t *testing.T) {
+	slow := &testNotifierSender{delay: time.Second}
+
+	// 仅实现 BotSender 的发送器不支持渲染通知
+	notifier := NewNotifier(&NotifierChannel{Name: "slow", Sender: slow}, &NotifierChannel{Name: "plain", Sender: struct{ BotSender }{slow}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := notifier.Notify(ctx, &Notification{Title: "x"})
+
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("Notify did not return after ctx was canceled")
+	}
+	if result.Results[0].Err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: %v", result.Results[0].Err)
+	}
+	if result.Results[1].Err == nil || !strings.Contains(result.Results[1].Err.Error(), "does not support") {
+		t.Errorf("unexpected error: %v", result.Results[1].Err)
+	}
+}
+
+func TestNotifier_UnknownChannel(t *testing.T) {
+	feishu := &testNotifierSender{}
+
+	notifier := NewNotifier().
+		AddChannel("feishu", feishu).
+		AddRoute(&NotifierRoute{MinSeverity: SeverityCritical, Channels: []string{"feishu", "pager"}})
+
+	if err := notifier.Validate(); err == nil || !strings.Contains(err.Error(), "pager") {
+		t.Errorf("Unexpected validate error. (%v)", err)
+	}
+
+	result := notifier.Notify(context.Background(), &Notification{Severity: SeverityCritical, Title: "down"})
+	if len(result.Results) != 2 || len(result.Failed()) != 1 || result.Failed()[0].Channel != "pager" {
+		t.Errorf("Unexpected results. (%+v)", result.Results)
+	}
+	if feishu.count() != 1 {
+		t.Errorf("Unexpected send count. (%d)", feishu.count())
+	}
+
+	notifier.AddChannel("pager", &testNotifierSender{})
+	if err := notifier.Validate(); err != nil {
+		t.Errorf("Unexpected validate error. (%v)", err)
+	}
+}
+
+func TestNotifier_UnknownChannelCancel(t *testing.T) {
+	notifier := NewNotifier(&NotifierChannel{Name: "slow", Sender: &testNotifierSender{delay: time.Second}})
+	notifier.DefaultChannels = []string{"slow", "missing"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result := notifier.Notify(ctx, &Notification{Title: "x"})
+	if len(result.Results) != 2 {
+		t.Fatalf("Unexpected results. (%+v)", result.Results)
+	}
+	if result.Results[0].Err != context.DeadlineExceeded {
+		t.Errorf("Unexpected error. (%v)", result.Results[0].Err)
+	}
+	if result.Results[1].Channel != "missing" || result.Results[1].Err == nil || !strings.Contains(result.Results[1].Err.Error(), "not registered") {
+		t.Errorf("Unexpected result. (%+v)", result.Results[1])
+	}
+}
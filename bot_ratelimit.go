@@ -0,0 +1,191 @@
+package goutils
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// ErrBotThrottled 发送频率超限。(可通过 errors.Is 判断 *BotError 是否为限流错误)
+var ErrBotThrottled = errors.New("The bot message was throttled by the platform.")
+
+// Is 支持 errors.Is(err, ErrBotThrottled)。
+func (e *BotError) Is(target error) bool {
+	return target == ErrBotThrottled && e.Throttled()
+}
+
+// BotRateLimit 发送频率限制 (Period 时间内最多发送 Limit 条消息)。
+type BotRateLimit struct {
+	Limit  int
+	Period time.Duration
+}
+
+// 各平台文档规定的 webhook 发送频率限制
+var (
+	// 飞书自定义机器人: 100 次/分钟, 5 次/秒
+	FeishuBotRateLimits = []BotRateLimit{{Limit: 5, Period: time.Second}, {Limit: 100, Period: time.Minute}}
+	// 钉钉自定义机器人: 20 条/分钟
+	DingtalkBotRateLimits = []BotRateLimit{{Limit: 20, Period: time.Minute}}
+	// 企业微信群机器人: 20 条/分钟
+	WxWorkBotRateLimits = []BotRateLimit{{Limit: 20, Period: time.Minute}}
+)
+
+// botWindow 滑动窗口。(记录最近的发送时间, 令牌桶无法保证任意 Period 时间内不超过 Limit 条)
+type botWindow struct {
+	BotRateLimit
+	times []time.Time
+}
+
+// next 返回下一条消息最早的发送时间。
+func (w *botWindow) next(now time.Time) time.Time {
+	cutoff := now.Add(-w.Period)
+
+	i := 0
+	for i < len(w.times) && !w.times[i].After(cutoff) {
+		i++
+	}
+	w.times = w.times[i:]
+
+	if len(w.times) < w.Limit {
+		return time.Time{}
+	}
+
+	return w.times[len(w.times)-w.Limit].Add(w.Period)
+}
+
+// botLimiter 单个机器人 (access token) 的发送频率限制。同一进程内使用相同 token 的发送器共享。
+type botLimiter struct {
+	mu      sync.Mutex
+	windows []*botWindow
+	// 平台返回限流错误后暂停发送至该时间
+	blockedUntil time.Time
+}
+
+func newBotLimiter(limits []BotRateLimit) *botLimiter {
+	l := &botLimiter{}
+
+	for _, v := range limits {
+		if v.Limit > 0 && v.Period > 0 {
+			l.windows = append(l.windows, &botWindow{BotRateLimit: v})
+		}
+	}
+
+	return l
+}
+
+// reserve 预占发送时间, 返回预占的时间。按调用顺序依次发送。
+func (l *botLimiter) reserve() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	at := now
+
+	if l.blockedUntil.After(at) {
+		at = l.blockedUntil
+	}
+
+	for _, w := range l.windows {
+		if t := w.next(now); t.After(at) {
+			at = t
+		}
+
+		if n := len(w.times); n > 0 && w.times[n-1].After(at) {
+			at = w.times[n-1]
+		}
+	}
+
+	for _, w := range l.windows {
+		w.times = append(w.times, at)
+	}
+
+	return at
+}
+
+// cancel 取消预占的发送时间。
+func (l *botLimiter) cancel(at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, w := range l.windows {
+		for i := len(w.times) - 1; i >= 0; i-- {
+			if w.times[i].Equal(at) {
+				w.times = append(w.times[:i], w.times[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// wait 等待至允许发送。ctx 取消时释放预占的发送时间并返回 ctx.Err()。
+func (l *botLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	at := l.reserve()
+
+	if err := sleepContext(ctx, time.Until(at)); err != nil {
+		l.cancel(at)
+		return err
+	}
+
+	return nil
+}
+
+// throttled 平台返回限流错误时, 暂停发送一个完整的时间窗口。
+func (l *botLimiter) throttled() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var period time.Duration
+	for _, w := range l.windows {
+		if w.Period > period {
+			period = w.Period
+		}
+	}
+
+	if until := time.Now().Add(period); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+var botLimiters sync.Map
+
+// getBotLimiter 按平台及 token 获取共享的频率限制。(首次创建时的 limits 生效)
+func getBotLimiter(platform, token string, disabled bool, limits, defaults []BotRateLimit) *botLimiter {
+	if disabled {
+		return nil
+	}
+
+	if limits == nil {
+		limits = defaults
+	}
+
+	key := platform + ":" + token
+
+	if v, ok := botLimiters.Load(key); ok {
+		return v.(*botLimiter)
+	}
+
+	v, _ := botLimiters.LoadOrStore(key, newBotLimiter(limits))
+
+	return v.(*botLimiter)
+}
+
+func (s *FeishuBotSender) limiter() *botLimiter {
+	return getBotLimiter("feishu", s.AccessToken, s.DisableRateLimit, s.RateLimits, FeishuBotRateLimits)
+}
+
+func (s *DingtalkBotSender) limiter() *botLimiter {
+	return getBotLimiter("dingtalk", s.AccessToken, s.DisableRateLimit, s.RateLimits, DingtalkBotRateLimits)
+}
+
+func (s *WxWorkBotSender) limiter() *botLimiter {
+	return getBotLimiter("wxwork", s.AccessToken, s.DisableRateLimit, s.RateLimits, WxWorkBotRateLimits)
+}
@@ -0,0 +1,111 @@
+package goutils
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBotLimiter_Reserve(t *testing.T) {
+	l := newBotLimiter([]BotRateLimit{{Limit: 2, Period: 100 * time.Millisecond}, {Limit: 3, Period: time.Second}})
+
+	// 前两条无需等待, 第三条需等待第一条移出 100ms 窗口
+	if d := time.Until(l.reserve()); d > time.Millisecond {
+		t.Errorf("Unexpected wait. (%s)", d)
+	}
+	if d := time.Until(l.reserve()); d > time.Millisecond {
+		t.Errorf("Unexpected wait. (%s)", d)
+	}
+	if d := time.Until(l.reserve()); d < 90*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("Unexpected wait. (%s)", d)
+	}
+
+	// 第四条受 1s 窗口限制
+	if d := time.Until(l.reserve()); d < 990*time.Millisecond || d > time.Second {
+		t.Errorf("Unexpected wait. (%s)", d)
+	}
+}
+
+func TestBotLimiter_WaitContext(t *testing.T) {
+	l := newBotLimiter([]BotRateLimit{{Limit: 1, Period: time.Hour}})
+
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("Unexpected error. (%v)", err)
+	}
+
+	// ctx 超时后中止等待并释放预占的发送时间
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := l.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Unexpected error. (%v)", err)
+	}
+
+	if n := len(l.windows[0].times); n != 1 {
+		t.Errorf("Unexpected reservations. (%d)", n)
+	}
+}
+
+func TestBotLimiter_Throttled(t *testing.T) {
+	l := newBotLimiter([]BotRateLimit{{Limit: 10, Period: 200 * time.Millisecond}})
+	l.throttled()
+
+	if d := time.Until(l.reserve()); d < 190*time.Millisecond {
+		t.Errorf("Unexpected wait. (%s)", d)
+	}
+
+	var nilLimiter *botLimiter
+	nilLimiter.wait(context.Background())
+	nilLimiter.throttled()
+}
+
+func TestBotError_Throttled(t *testing.T) {
+	err := errors.Wrap(&BotError{Platform: "dingtalk", Code: 130101, Message: "send too fast"}, "send")
+	if !errors.Is(err, ErrBotThrottled) {
+		t.Errorf("The error should be recognized as throttled.")
+	}
+
+	if errors.Is(&BotError{Platform: "dingtalk", Code: 310000, Message: "keywords not in content"}, ErrBotThrottled) {
+		t.Errorf("The error should not be recognized as throttled.")
+	}
+}
+
+func TestWxWorkBotSender_RateLimit(t *testing.T) {
+	var hits int
+
+	// 模拟企业微信返回限流错误码
+	client := NewHttpClient(HttpClientOptionWithMiddleware(func(next HttpRoundTripFunc) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			hits++
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"errcode":45009,"errmsg":"api freq out of limit"}`)),
+				Request:    req,
+			}, nil
+		}
+	}))
+
+	sender := &WxWorkBotSender{
+		AccessToken: "ratelimit-test",
+		Client:      client,
+		RateLimits:  []BotRateLimit{{Limit: 5, Period: 200 * time.Millisecond}},
+	}
+
+	err := sender.Send(NewWxWorkTextMessage("hello"))
+	if !errors.Is(err, ErrBotThrottled) {
+		t.Fatalf("Unexpected error. (%v)", err)
+	}
+
+	// 平台返回限流错误后暂停一个窗口
+	start := time.Now()
+	sender.Send(NewWxWorkTextMessage("hello"))
+	if d := time.Since(start); d < 150*time.Millisecond || hits != 2 {
+		t.Errorf("Unexpected wait. (%s, hits: %d)", d, hits)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"github.com/pkg/errors"
 	"hash"
 	"math/big"
 	"net"
@@ -84,6 +85,26 @@ func Hash(s string, algorithm HashAlgorithm, capital bool) string {
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+// newHash 按算法名称创建 hash.Hash 对象。
+func newHash(algorithm HashAlgorithm) (hash.Hash, error) {
+	switch algorithm {
+	case "md5", "MD5":
+		return md5.New(), nil
+	case "sha1", "SHA1":
+		return sha1.New(), nil
+	case "sha224", "SHA224":
+		return sha256.New224(), nil
+	case "sha256", "SHA256":
+		return sha256.New(), nil
+	case "sha384", "SHA384":
+		return sha512.New384(), nil
+	case "sha512", "SHA512":
+		return sha512.New(), nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("Unsupported hash algorithm. (%s)", algorithm))
+}
+
 func HMD5(s, key string) string {
 	return HMAC(s, key, Md5, false)
 }
@@ -3,6 +3,7 @@ module github.com/designinlife/goutils
 go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/dustin/go-humanize v1.0.0
 	github.com/georgysavva/scany v0.2.9
 	github.com/go-sql-driver/mysql v1.6.0
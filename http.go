@@ -2,6 +2,7 @@ package goutils
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
@@ -9,15 +10,14 @@ import (
 	"fmt"
 	"github.com/dustin/go-humanize"
 	"github.com/mattn/go-isatty"
-	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -25,6 +25,22 @@ import (
 type HttpClient struct {
 	ProgressBar ProgressBar
 	Transport   *http.Transport
+	Retry       *HttpRetryPolicy
+	Middlewares []HttpMiddleware
+	// 请求体压缩阈值 (字节)。大于 0 时, 超过该大小的 JSON/XML 请求体将使用 gzip 压缩
+	CompressMinSize int
+	// GET 响应缓存
+	Cache HttpCache
+	// 默认认证方式
+	Auth HttpAuthProvider
+	// 代理规则
+	ProxyRules []HttpProxyRule
+	// 不使用代理的主机列表
+	NoProxy []string
+
+	tlsConfig       *tls.Config
+	rateLimiter     *httpRateLimiter
+	proxyTransports proxyTransportCache
 }
 
 type ProgressBar interface {
@@ -46,24 +62,42 @@ type HttpRequest struct {
 	CookieJar http.CookieJar
 	// POST 表单参数
 	FormParams map[string]interface{}
+	// multipart/form-data 上传文件
+	Files []*HttpFormFile
 	// Text 数据参数
 	Text string
 	// JSON 数据参数
 	JSON interface{}
 	// XML 数据参数
 	XML interface{}
-	// 代理服务器地址
+	// 代理服务器地址 (支持 http/https/socks5 协议, 多个代理以逗号分隔时按顺序串联)
 	Proxy string
 	// 是否服务器响应非 200 状态时，返回 error？
 	AllowNon200Response bool
 	// 下载到本地文件
 	ToFile string
+	// 是否启用断点续传？(下载失败时保留 .tmp 文件, 再次请求时通过 Range/If-Range 继续下载)
+	Resume bool
+	// 分段并发下载的连接数 (仅 GET 请求且服务器支持 Range 时生效, 不支持与 Resume 同时使用)
+	Connections int
+	// 下载文件的期望哈希值 (十六进制)。校验失败时删除临时文件并返回 *ChecksumMismatchError 错误
+	Checksum string
+	// 下载文件的哈希算法 (默认值: sha256)
+	ChecksumAlgorithm HashAlgorithm
+	// 是否以流式方式读取响应？(响应内容不缓存至 Body, 通过 HttpResponse.Reader 读取, 调用方负责关闭)
+	Stream bool
 	// 是否显示进度条？
 	ProgressBar bool
 	// HTTP Basic 认证用户名
 	Username string
 	// HTTP Basic 认证密码
 	Password string
+	// 认证方式 (Bearer Token, OAuth2, HMAC 签名等, 优先于 HttpClient.Auth)
+	Auth HttpAuthProvider
+	// 是否记录请求耗时明细？(DNS 解析、TCP 连接、TLS 握手、首字节及传输耗时, 通过 HttpResponse.Timing 获取, 不支持分段并发下载)
+	Trace bool
+	// 是否记录请求及响应内容？(调试用途, 敏感请求头将被隐藏, 通过 HttpResponse.Dump 获取)
+	Dump bool
 }
 
 type HttpResponse struct {
@@ -73,6 +107,12 @@ type HttpResponse struct {
 	ContentType   string
 	ContentLength int64
 	Body          []byte
+	// 流式响应内容 (仅 HttpRequest.Stream 为 true 时有效)
+	Reader io.ReadCloser `json:"-"`
+	// 请求耗时明细 (仅 HttpRequest.Trace 为 true 时有效)
+	Timing *HttpTiming `json:"-"`
+	// 请求及响应内容 (仅 HttpRequest.Dump 为 true 时有效, 发生重试或多连接下载时包含每次请求)
+	Dump []byte `json:"-"`
 }
 
 func (h HttpResponse) String() string {
@@ -114,6 +154,15 @@ func NewHttpClient(opts ...HttpClientOption) *HttpClient {
 		opt(c)
 	}
 
+	// 指定了 TLS 参数时使用独立的连接池, 否则共享默认连接池
+	if c.tlsConfig != nil {
+		if c.Transport != nil {
+			c.Transport = withTLSConfig(c.Transport, c.tlsConfig)
+		} else {
+			c.Transport = newHttpTransport(c.tlsConfig)
+		}
+	}
+
 	return c
 }
 
@@ -135,8 +184,215 @@ func basicAuth(username, password string) string {
 }
 
 func (h *HttpClient) Request(method, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(context.Background(), method, uri, r)
+}
+
+// RequestContext 发送 HTTP 请求。ctx 取消或超时时中止连接、上传及下载过程。
+func (h *HttpClient) RequestContext(ctx context.Context, method, uri string, r *HttpRequest) (*HttpResponse, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if h.Cache != nil && h.isCacheableRequest(method, r) {
+		return h.cachedRequest(ctx, method, uri, r)
+	}
+
+	return h.request(ctx, method, uri, r)
+}
+
+func (h *HttpClient) request(ctx context.Context, method, uri string, r *HttpRequest) (*HttpResponse, error) {
+	// 创建客户端并发送请求
+	tr := h.Transport
+
+	if tr == nil {
+		tr = defaultHttpTransport
+	}
+
+	// 按请求选择代理 (不修改共享的 Transport)
+	if chain := h.proxyChain(r, uri); chain != nil {
+		var err error
+
+		if tr, err = h.proxyTransport(tr, chain); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := time.Second * 60
+
+	if r != nil && r.Timeout > 0 {
+		timeout = r.Timeout
+	}
+
+	// 流式读取时超时时长包含读取响应内容的时间, 仅在显式指定时生效
+	if r != nil && r.Stream {
+		timeout = r.Timeout
+	}
+
+	var (
+		rt     http.RoundTripper = tr
+		tracer *httpTracer
+		dump   *dumpTransport
+	)
+
+	if r != nil && r.Trace {
+		tracer = &httpTracer{}
+		ctx = httptrace.WithClientTrace(ctx, tracer.clientTrace())
+	}
+
+	// 在中间件链内层记录实际发送的内容。流式读取、下载及上传文件时不输出请求体/响应体, 以免读入内存
+	if r != nil && r.Dump {
+		dump = &dumpTransport{next: tr, body: !r.Stream && r.ToFile == "" && len(r.Files) == 0}
+		rt = dump
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: h.roundTripper(rt),
+	}
+
+	if r != nil && r.CookieJar != nil {
+		client.Jar = r.CookieJar
+	}
+
+	// 附加耗时明细及调试内容
+	attach := func(ret *HttpResponse, transfer bool) *HttpResponse {
+		if tracer != nil {
+			ret.Timing = tracer.timing(transfer)
+		}
+		if dump != nil {
+			ret.Dump = dump.bytes()
+		}
+		return ret
+	}
+
+	if r != nil && r.ToFile != "" {
+		ret, err := h.download(ctx, client, method, uri, r)
+		if err != nil {
+			return nil, err
+		}
+
+		return attach(ret, true), nil
+	}
+
+	resp, err := h.do(ctx, client, method, uri, r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decodeResponseBody(resp)
+
+	if r != nil && r.Stream {
+		if err = checkResponseStatus(resp, r); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+
+		ret := newHttpResponse(resp, nil)
+		ret.Reader = resp.Body
+
+		return attach(ret, false), nil
+	}
+
+	defer resp.Body.Close()
+
+	if err = checkResponseStatus(resp, r); err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return attach(newHttpResponse(resp, content), true), nil
+}
+
+// checkResponseStatus 检查非 200 响应状态。
+func checkResponseStatus(resp *http.Response, r *HttpRequest) error {
+	allowNon200 := false
+
+	if r != nil {
+		allowNon200 = r.AllowNon200Response
+	}
+
+	if !allowNon200 && !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		return newHttpError(resp)
+	}
+
+	return nil
+}
+
+// 错误响应体最大读取字节数
+const maxHttpErrorBodySize = 1024 * 1024
+
+// HttpError 服务器返回非 2xx 响应状态时的错误信息。(可通过 errors.As 获取)
+type HttpError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("A non-200 response status code was detected. (%s %s, StatusCode: %d)", e.Method, e.URL, e.StatusCode)
+}
+
+// newHttpError 读取响应内容 (最多 1MB) 并创建 *HttpError 对象。
+func newHttpError(resp *http.Response) *HttpError {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxHttpErrorBodySize))
+
+	e := &HttpError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+
+	return e
+}
+
+func newHttpResponse(resp *http.Response, content []byte) *HttpResponse {
+	return &HttpResponse{
+		RequestURI:    resp.Request.RequestURI,
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		Body:          content,
+	}
+}
+
+// progressWriter 返回下载进度输出对象及结束回调。(未开启进度条或非终端环境时不输出)
+func (h *HttpClient) progressWriter(r *HttpRequest, totalSize uint64) (io.Writer, func()) {
+	if !r.ProgressBar || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return ioutil.Discard, func() {}
+	}
+
+	if h.ProgressBar != nil {
+		h.ProgressBar.SetTotalBytes(totalSize)
+
+		return h.ProgressBar, func() { _ = h.ProgressBar.Close() }
+	}
+
+	counter := &progressBarCounter{ProgressBar: true, TotalBytes: totalSize, SimpleBarStyle: true}
+
+	return counter, func() { _ = counter.Close() }
+}
+
+// newRequest 根据 HttpRequest 参数构建 *http.Request 对象。（每次重试均需重新构建请求体）
+func (h *HttpClient) newRequest(ctx context.Context, method, uri string, r *HttpRequest) (*http.Request, error) {
 	// 创建 HTTP 客户端实例
-	req, _ := http.NewRequest(method, uri, nil)
+	req, err := http.NewRequestWithContext(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	// 设置 Headers
 	if r != nil && r.Headers != nil {
@@ -157,6 +413,11 @@ func (h *HttpClient) Request(method, uri string, r *HttpRequest) (*HttpResponse,
 		req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	}
 
+	// 声明支持的压缩格式 (由 decodeResponseBody 解码)。下载文件时保持原样, 以免影响 Range 请求
+	if req.Header.Get("Accept-Encoding") == "" && (r == nil || r.ToFile == "") {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
 	// 设置 Cookies
 	if r != nil {
 		// HTTP Basic Auth
@@ -185,31 +446,18 @@ func (h *HttpClient) Request(method, uri string, r *HttpRequest) (*HttpResponse,
 	}
 
 	// 设置 Query 查询参数
-	if r != nil {
-		switch r.Query.(type) {
-		case string:
-			str := r.Query.(string)
-			req.URL.RawQuery = str
-		case map[string]interface{}:
-			q := req.URL.Query()
-			for k, v := range r.Query.(map[string]interface{}) {
-				if vv, ok := v.(string); ok {
-					q.Set(k, vv)
-					continue
-				}
-				if vv, ok := v.([]string); ok {
-					for _, vvv := range vv {
-						q.Add(k, vvv)
-					}
-				}
-			}
-			req.URL.RawQuery = q.Encode()
+	applyQuery(req.URL, r)
+
+	// 设置 multipart/form-data 上传文件 (FormParams 作为普通表单字段一同提交)
+	if r != nil && len(r.Files) > 0 {
+		if err := h.setMultipartBody(req, r); err != nil {
+			return nil, err
 		}
 	}
 
 	// 设置 Form 表单参数
 	if r != nil {
-		if r.FormParams != nil {
+		if r.FormParams != nil && len(r.Files) == 0 {
 			if _, ok := r.Headers["Content-Type"]; !ok {
 				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 			}
@@ -287,146 +535,57 @@ func (h *HttpClient) Request(method, uri string, r *HttpRequest) (*HttpResponse,
 		}
 	}
 
-	// 创建客户端并发送请求
-	var tr *http.Transport
-
-	if h.Transport == nil {
-		tr = &http.Transport{
-			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
-			DisableKeepAlives: true,
-		}
-	} else {
-		tr = h.Transport
-	}
-
-	if r != nil && r.Proxy != "" {
-		proxy, err := url.Parse(r.Proxy)
-		if err == nil {
-			tr.Proxy = http.ProxyURL(proxy)
+	// 压缩 JSON/XML 请求体
+	if r != nil && (r.JSON != nil || r.XML != nil) {
+		if err := h.compressRequestBody(req); err != nil {
+			return nil, err
 		}
 	}
 
-	timeout := time.Second * 60
+	// 设置认证信息 (须在请求体构建完成后执行, 以便签名类认证读取请求体)
+	auth := h.Auth
 
-	if r != nil && r.Timeout > 0 {
-		timeout = r.Timeout
-	}
-
-	client := &http.Client{
-		Timeout:   timeout,
-		Transport: tr,
-	}
-
-	if r.CookieJar != nil {
-		client.Jar = r.CookieJar
-	}
-
-	resp, err := client.Do(req)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	// 检查非 200 响应状态
-	allowNon200 := false
-
-	if r != nil {
-		allowNon200 = r.AllowNon200Response
+	if r != nil && r.Auth != nil {
+		auth = r.Auth
 	}
 
-	if !allowNon200 && !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
-		return nil, errors.New(fmt.Sprintf("A non-200 response status code was detected. (StatusCode: %d)", resp.StatusCode))
-	}
-
-	var content []byte
-
-	if r != nil && r.ToFile != "" {
-		filename := r.ToFile
-
-		dirn := filepath.Dir(filename)
-
-		if !IsDir(dirn) {
-			if err := os.MkdirAll(dirn, 0755); err != nil {
-				return nil, err
+	if auth != nil {
+		if err := auth.Authorize(req); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
 			}
-		}
-
-		out, err := os.Create(filename + ".tmp")
-		if err != nil {
 			return nil, err
 		}
+	}
 
-		// 此处不能使用 defer 方式关闭 out 资源，因为在 os.Rename 时资源句柄未释放造成重命名出错！
-		// defer out.Close()
-
-		var totalSize uint64
-
-		totalSize = 0
-		contentSize := resp.Header.Get("Content-Length")
-
-		if contentSize != "" {
-			intNum, _ := strconv.Atoi(contentSize)
-			totalSize = uint64(intNum)
-		}
-
-		showProgressBar := false
-
-		if isatty.IsTerminal(os.Stdout.Fd()) && r.ProgressBar {
-			showProgressBar = true
-		}
-
-		if showProgressBar {
-			if h.ProgressBar != nil {
-				h.ProgressBar.SetTotalBytes(totalSize)
-
-				if _, err = io.Copy(out, io.TeeReader(resp.Body, h.ProgressBar)); err != nil {
-					out.Close()
-					return nil, err
-				}
-
-				_ = h.ProgressBar.Close()
-			} else {
-				counter := &progressBarCounter{ProgressBar: true, TotalBytes: totalSize, SimpleBarStyle: true}
+	return req, nil
+}
 
-				if _, err = io.Copy(out, io.TeeReader(resp.Body, counter)); err != nil {
-					out.Close()
-					return nil, err
-				}
+// applyQuery 将 HttpRequest.Query 查询参数写入 URL。
+func applyQuery(u *url.URL, r *HttpRequest) {
+	if r == nil {
+		return
+	}
 
-				_ = counter.Close()
+	switch r.Query.(type) {
+	case string:
+		str := r.Query.(string)
+		u.RawQuery = str
+	case map[string]interface{}:
+		q := u.Query()
+		for k, v := range r.Query.(map[string]interface{}) {
+			if vv, ok := v.(string); ok {
+				q.Set(k, vv)
+				continue
 			}
-		} else {
-			if _, err = io.Copy(out, resp.Body); err != nil {
-				out.Close()
-				return nil, err
+			if vv, ok := v.([]string); ok {
+				for _, vvv := range vv {
+					q.Add(k, vvv)
+				}
 			}
 		}
-
-		out.Close()
-
-		if err = os.Rename(filename+".tmp", filename); err != nil {
-			return nil, err
-		}
-	} else {
-		content, err = ioutil.ReadAll(resp.Body)
-
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	ret := &HttpResponse{
-		RequestURI:    resp.Request.RequestURI,
-		StatusCode:    resp.StatusCode,
-		Header:        resp.Header,
-		ContentType:   resp.Header.Get("Content-Type"),
-		ContentLength: resp.ContentLength,
-		Body:          content,
+		u.RawQuery = q.Encode()
 	}
-
-	return ret, nil
 }
 
 func (h *HttpClient) Get(uri string, r *HttpRequest) (*HttpResponse, error) {
@@ -457,6 +616,34 @@ func (h *HttpClient) Patch(uri string, r *HttpRequest) (*HttpResponse, error) {
 	return h.Request("PATCH", uri, r)
 }
 
+func (h *HttpClient) GetContext(ctx context.Context, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(ctx, "GET", uri, r)
+}
+
+func (h *HttpClient) PostContext(ctx context.Context, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(ctx, "POST", uri, r)
+}
+
+func (h *HttpClient) PutContext(ctx context.Context, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(ctx, "PUT", uri, r)
+}
+
+func (h *HttpClient) DeleteContext(ctx context.Context, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(ctx, "DELETE", uri, r)
+}
+
+func (h *HttpClient) OptionsContext(ctx context.Context, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(ctx, "OPTIONS", uri, r)
+}
+
+func (h *HttpClient) HeadContext(ctx context.Context, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(ctx, "HEAD", uri, r)
+}
+
+func (h *HttpClient) PatchContext(ctx context.Context, uri string, r *HttpRequest) (*HttpResponse, error) {
+	return h.RequestContext(ctx, "PATCH", uri, r)
+}
+
 func map2XML(m map[string]string, opts ...interface{}) ([]byte, error) {
 	rootTag := "xml"
 	if len(opts) > 0 {
@@ -0,0 +1,216 @@
+package goutils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HttpAuthProvider 请求认证接口。每次发送请求 (包括重试) 前调用。
+type HttpAuthProvider interface {
+	Authorize(req *http.Request) error
+}
+
+// HttpClientOptionWithAuth 设置默认认证方式。(HttpRequest.Auth 优先)
+func HttpClientOptionWithAuth(p HttpAuthProvider) HttpClientOption {
+	return func(c *HttpClient) {
+		c.Auth = p
+	}
+}
+
+// HttpBearerAuth 固定 Bearer Token 认证。
+type HttpBearerAuth struct {
+	Token string
+}
+
+func (a *HttpBearerAuth) Authorize(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	return nil
+}
+
+// cachedToken 缓存访问令牌, 过期前自动刷新。
+type cachedToken struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// get 读取令牌。令牌不存在或距离过期不足 delta 时通过 fetch 重新获取。
+func (t *cachedToken) get(delta time.Duration, fetch func() (string, time.Duration, error)) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Now().Add(delta).Before(t.expiry) {
+		return t.token, nil
+	}
+
+	token, expiresIn, err := fetch()
+	if err != nil {
+		return "", err
+	}
+
+	t.token = token
+	t.expiry = time.Now().Add(expiresIn)
+
+	return token, nil
+}
+
+// HttpOAuth2ClientCredentials OAuth2 客户端凭证模式 (client_credentials) 认证。令牌过期前自动刷新。
+type HttpOAuth2ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// 额外的令牌请求参数
+	EndpointParams map[string]string
+	// 是否在请求体中提交客户端凭证？(默认使用 HTTP Basic 认证)
+	CredentialsInBody bool
+	// 提前刷新时长 (默认值: 60s)
+	ExpiryDelta time.Duration
+	// 获取令牌使用的 HTTP 客户端 (默认值: NewHttpClient())
+	Client *HttpClient
+
+	cache cachedToken
+}
+
+func (a *HttpOAuth2ClientCredentials) Authorize(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+// Token 获取访问令牌。
+func (a *HttpOAuth2ClientCredentials) Token(ctx context.Context) (string, error) {
+	delta := a.ExpiryDelta
+	if delta <= 0 {
+		delta = time.Second * 60
+	}
+
+	return a.cache.get(delta, func() (string, time.Duration, error) {
+		client := a.Client
+		if client == nil {
+			client = NewHttpClient()
+		}
+
+		params := map[string]interface{}{"grant_type": "client_credentials"}
+
+		if len(a.Scopes) > 0 {
+			params["scope"] = strings.Join(a.Scopes, " ")
+		}
+
+		for k, v := range a.EndpointParams {
+			params[k] = v
+		}
+
+		r := &HttpRequest{FormParams: params}
+
+		if a.CredentialsInBody {
+			params["client_id"] = a.ClientID
+			params["client_secret"] = a.ClientSecret
+		} else {
+			r.Username = a.ClientID
+			r.Password = a.ClientSecret
+		}
+
+		resp, err := client.PostContext(ctx, a.TokenURL, r)
+		if err != nil {
+			return "", 0, err
+		}
+
+		r1 := struct {
+			AccessToken string      `json:"access_token"`
+			TokenType   string      `json:"token_type"`
+			ExpiresIn   json.Number `json:"expires_in"`
+		}{}
+
+		if err = resp.ToJson(&r1); err != nil {
+			return "", 0, errors.Errorf("Token response parse error: %v", err)
+		}
+
+		if r1.AccessToken == "" {
+			return "", 0, errors.New("The token response does not contain an access_token.")
+		}
+
+		expiresIn, _ := r1.ExpiresIn.Int64()
+		if expiresIn <= 0 {
+			expiresIn = 3600
+		}
+
+		return r1.AccessToken, time.Duration(expiresIn) * time.Second, nil
+	})
+}
+
+// HttpHMACAuth HMAC 请求签名认证。
+// 默认签名字符串为: METHOD + "\n" + PATH?QUERY + "\n" + TIMESTAMP + "\n" + HEX(SHA256(BODY))，
+// 签名结果 (十六进制) 及相关信息写入 X-Key-Id, X-Timestamp, X-Signature 请求头。
+// multipart 请求体 (HttpRequest.Files) 不参与签名 (按空请求体计算), 以免上传文件被完整读入内存。
+type HttpHMACAuth struct {
+	KeyID  string
+	Secret string
+	// 签名算法 (默认值: sha256)
+	Algorithm HashAlgorithm
+	// 自定义签名字符串
+	StringToSign func(req *http.Request, body []byte, timestamp string) string
+}
+
+func (a *HttpHMACAuth) Authorize(req *http.Request) error {
+	var body []byte
+
+	multipart := strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/")
+
+	if req.Body != nil && req.Body != http.NoBody && !multipart {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	var stringToSign string
+
+	if a.StringToSign != nil {
+		stringToSign = a.StringToSign(req, body, timestamp)
+	} else {
+		sum := sha256.Sum256(body)
+		stringToSign = fmt.Sprintf("%s\n%s\n%s\n%s", req.Method, req.URL.RequestURI(), timestamp, hex.EncodeToString(sum[:]))
+	}
+
+	algorithm := a.Algorithm
+	if algorithm == "" {
+		algorithm = Sha256
+	}
+
+	signature := HMAC(stringToSign, a.Secret, algorithm, false)
+	if signature == "" {
+		return errors.New(fmt.Sprintf("Unsupported hash algorithm. (%s)", algorithm))
+	}
+
+	if a.KeyID != "" {
+		req.Header.Set("X-Key-Id", a.KeyID)
+	}
+	req.Header.Set("X-Timestamp", timestamp)
+	req.Header.Set("X-Signature", signature)
+
+	return nil
+}
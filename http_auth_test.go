@@ -0,0 +1,98 @@
+package goutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHttpOAuth2ClientCredentials(t *testing.T) {
+	var issued int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			id, secret, _ := r.BasicAuth()
+			if id != "client" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "a b" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			n := atomic.AddInt32(&issued, 1)
+			fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+		default:
+			w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer ts.Close()
+
+	auth := &HttpOAuth2ClientCredentials{
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"a", "b"},
+	}
+
+	client := NewHttpClient(HttpClientOptionWithAuth(auth))
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL+"/api", nil)
+		if err != nil {
+			t.Fatalf("Request errors. (%v)", err)
+		}
+
+		if resp.ToString() != "Bearer token-1" {
+			t.Errorf("Unexpected authorization. (%s)", resp.ToString())
+		}
+	}
+
+	// HttpRequest.Auth 优先
+	resp, err := client.Get(ts.URL+"/api", &HttpRequest{Auth: &HttpBearerAuth{Token: "static"}})
+	if err != nil || resp.ToString() != "Bearer static" {
+		t.Errorf("Unexpected authorization. (%v)", err)
+	}
+}
+
+func TestHttpHMACAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+
+		expected := HMAC(fmt.Sprintf("%s\n%s\n%s\n%s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Timestamp"), hex.EncodeToString(sum[:])), "secret", Sha256, false)
+
+		if r.Header.Get("X-Key-Id") != "key" || r.Header.Get("X-Signature") != expected {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewHttpClient()
+	_, err := client.Post(ts.URL+"/sign?a=1", &HttpRequest{
+		JSON: map[string]string{"foo": "bar"},
+		Auth: &HttpHMACAuth{KeyID: "key", Secret: "secret"},
+	})
+	if err != nil {
+		t.Errorf("Request errors. (%v)", err)
+	}
+
+	// multipart 请求体按空请求体签名
+	filename := filepath.Join(t.TempDir(), "upload.txt")
+	ioutil.WriteFile(filename, []byte("hello"), 0644)
+
+	client.Post(ts.URL+"/upload", &HttpRequest{
+		Files: []*HttpFormFile{{FieldName: "file", Path: filename}},
+		Auth: &HttpHMACAuth{KeyID: "key", Secret: "secret", StringToSign: func(req *http.Request, body []byte, timestamp string) string {
+			if len(body) != 0 {
+				t.Errorf("The multipart body should not be signed.")
+			}
+
+			return req.Method + timestamp
+		}},
+	})
+}
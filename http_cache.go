@@ -0,0 +1,394 @@
+package goutils
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HttpCache HTTP 响应缓存存储接口。
+type HttpCache interface {
+	Get(key string) (*HttpCacheEntry, bool)
+	Set(key string, entry *HttpCacheEntry)
+	Delete(key string)
+}
+
+// HttpCacheEntry 缓存条目。
+type HttpCacheEntry struct {
+	Response *HttpResponse
+	// 缓存写入时间
+	StoredAt time.Time
+	// 缓存过期时间 (过期后需向服务器重新校验)
+	Expires time.Time
+}
+
+func (e *HttpCacheEntry) fresh() bool {
+	return time.Now().Before(e.Expires)
+}
+
+// response 复制缓存的响应对象, 避免调用方修改缓存内容。
+func (e *HttpCacheEntry) response() *HttpResponse {
+	ret := *e.Response
+	ret.Header = e.Response.Header.Clone()
+
+	return &ret
+}
+
+// HttpClientOptionWithCache 启用 GET 响应缓存。
+// 遵循 Cache-Control (max-age, no-cache, no-store, private) 及 Expires 响应头，过期后通过 If-None-Match / If-Modified-Since 重新校验，
+// 服务器返回 304 时使用缓存内容。(不支持 Vary 响应头)
+//
+// 缓存键不包含认证信息, 携带认证信息 (Authorization、Cookie、CookieJar、Basic 认证及 Auth) 的请求不使用缓存。
+// 通过中间件添加认证信息的请求无法识别, 不应与缓存同时使用。
+func HttpClientOptionWithCache(cache HttpCache) HttpClientOption {
+	return func(c *HttpClient) {
+		c.Cache = cache
+	}
+}
+
+// isCacheableRequest 仅缓存未携带认证信息的普通 GET 请求。(不包括下载文件、流式读取及请求头包含 Cache-Control: no-store 的请求)
+func (h *HttpClient) isCacheableRequest(method string, r *HttpRequest) bool {
+	if strings.ToUpper(method) != "GET" || h.Auth != nil {
+		return false
+	}
+
+	if r == nil {
+		return true
+	}
+
+	if r.ToFile != "" || r.Stream {
+		return false
+	}
+
+	if r.Auth != nil || r.Username != "" || r.Password != "" || r.Cookies != nil || r.CookieJar != nil {
+		return false
+	}
+
+	for k, v := range r.Headers {
+		switch http.CanonicalHeaderKey(k) {
+		case "Authorization", "Proxy-Authorization", "Cookie":
+			return false
+		case "Cache-Control":
+			if _, ok := cacheControl(http.Header{"Cache-Control": {fmt.Sprint(v)}})["no-store"]; ok {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// requestURL 返回包含查询参数的完整 URL。
+func requestURL(uri string, r *HttpRequest) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	applyQuery(u, r)
+
+	return u.String()
+}
+
+func (h *HttpClient) cachedRequest(ctx context.Context, method, uri string, r *HttpRequest) (*HttpResponse, error) {
+	fullURL := requestURL(uri, r)
+	key := strings.ToUpper(method) + " " + fullURL
+
+	entry, ok := h.Cache.Get(key)
+
+	if ok && entry.fresh() {
+		return entry.response(), nil
+	}
+
+	rr := &HttpRequest{}
+	if r != nil {
+		rr = r
+	}
+
+	headers := map[string]string{}
+
+	if ok {
+		if v := entry.Response.Header.Get("ETag"); v != "" {
+			headers["If-None-Match"] = v
+		}
+		if v := entry.Response.Header.Get("Last-Modified"); v != "" {
+			headers["If-Modified-Since"] = v
+		}
+	}
+
+	rr = withRequestHeaders(rr, headers)
+	rr.AllowNon200Response = true
+
+	resp, err := h.request(ctx, method, uri, rr)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		// 使用 304 响应头更新缓存 (创建新的条目, 缓存中的条目可能正被其它请求读取)
+		updated := entry.response()
+		for k, v := range resp.Header {
+			updated.Header[k] = v
+		}
+
+		now := time.Now()
+		entry = &HttpCacheEntry{
+			Response: updated,
+			StoredAt: now,
+			Expires:  cacheExpires(updated.Header, now),
+		}
+		h.Cache.Set(key, entry)
+
+		return entry.response(), nil
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		if storable(resp.Header) {
+			now := time.Now()
+			h.Cache.Set(key, &HttpCacheEntry{
+				Response: resp,
+				StoredAt: now,
+				Expires:  cacheExpires(resp.Header, now),
+			})
+			resp = (&HttpCacheEntry{Response: resp}).response()
+		} else if ok {
+			h.Cache.Delete(key)
+		}
+	}
+
+	if (r == nil || !r.AllowNon200Response) && !(resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		return nil, &HttpError{
+			Method:     strings.ToUpper(method),
+			URL:        fullURL,
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       resp.Body,
+		}
+	}
+
+	return resp, nil
+}
+
+// cacheControl 解析 Cache-Control 响应头。
+func cacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+
+	for _, line := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			if i := strings.Index(part, "="); i >= 0 {
+				directives[strings.ToLower(strings.TrimSpace(part[:i]))] = strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+			} else {
+				directives[strings.ToLower(part)] = ""
+			}
+		}
+	}
+
+	return directives
+}
+
+// storable 检查响应是否允许缓存？(no-store、private 或缺少过期时间及校验值的响应不缓存)
+func storable(header http.Header) bool {
+	cc := cacheControl(header)
+
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+
+	if _, ok := cc["private"]; ok {
+		return false
+	}
+
+	if header.Get("ETag") != "" || header.Get("Last-Modified") != "" {
+		return true
+	}
+
+	return cacheExpires(header, time.Now()).After(time.Now())
+}
+
+// cacheExpires 根据 Cache-Control: max-age 或 Expires 计算缓存过期时间。
+func cacheExpires(header http.Header, storedAt time.Time) time.Time {
+	cc := cacheControl(header)
+
+	if _, ok := cc["no-cache"]; ok {
+		return storedAt
+	}
+
+	if v, ok := cc["max-age"]; ok {
+		maxAge, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return storedAt
+		}
+
+		if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil {
+			maxAge -= age
+		}
+
+		return storedAt.Add(time.Duration(maxAge) * time.Second)
+	}
+
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return storedAt
+		}
+
+		// 以服务器时间为准计算剩余有效期
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			return storedAt.Add(expires.Sub(date))
+		}
+
+		return expires
+	}
+
+	return storedAt
+}
+
+// HttpMemoryCache 基于 LRU 淘汰策略的内存缓存。
+type HttpMemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type httpMemoryCacheItem struct {
+	key   string
+	entry *HttpCacheEntry
+}
+
+// NewHttpMemoryCache 创建内存缓存。capacity 为最大缓存条目数 (默认值: 256)
+func NewHttpMemoryCache(capacity int) *HttpMemoryCache {
+	if capacity <= 0 {
+		capacity = 256
+	}
+
+	return &HttpMemoryCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *HttpMemoryCache) Get(key string) (*HttpCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*httpMemoryCacheItem).entry, true
+	}
+
+	return nil, false
+}
+
+func (c *HttpMemoryCache) Set(key string, entry *HttpCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*httpMemoryCacheItem).entry = entry
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&httpMemoryCacheItem{key: key, entry: entry})
+
+	for c.ll.Len() > c.capacity {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*httpMemoryCacheItem).key)
+	}
+}
+
+func (c *HttpMemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.Remove(e)
+		delete(c.items, key)
+	}
+}
+
+// HttpDiskCache 基于本地目录的文件缓存。(每个条目保存为一个 JSON 文件)
+type HttpDiskCache struct {
+	Dir string
+}
+
+func NewHttpDiskCache(dir string) *HttpDiskCache {
+	return &HttpDiskCache{Dir: dir}
+}
+
+func (c *HttpDiskCache) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *HttpDiskCache) Get(key string) (*HttpCacheEntry, bool) {
+	b, err := ioutil.ReadFile(c.filename(key))
+	if err != nil {
+		return nil, false
+	}
+
+	entry := &HttpCacheEntry{}
+	if err = json.Unmarshal(b, entry); err != nil || entry.Response == nil {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (c *HttpDiskCache) Set(key string, entry *HttpCacheEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	if !IsDir(c.Dir) {
+		if err = os.MkdirAll(c.Dir, 0755); err != nil {
+			return
+		}
+	}
+
+	filename := c.filename(key)
+
+	// 先写入临时文件再重命名, 避免并发读取到不完整的内容
+	tmp, err := ioutil.TempFile(c.Dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(b)
+	tmp.Close()
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	if err = os.Rename(tmp.Name(), filename); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (c *HttpDiskCache) Delete(key string) {
+	os.Remove(c.filename(key))
+}
@@ -0,0 +1,110 @@
+package goutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHttpClient_Cache(t *testing.T) {
+	var hits, notModified int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		w.Header().Set("ETag", `"v1"`)
+
+		if r.URL.Path == "/fresh" {
+			w.Header().Set("Cache-Control", "max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	for _, cache := range []HttpCache{NewHttpMemoryCache(8), NewHttpDiskCache(t.TempDir())} {
+		atomic.StoreInt32(&hits, 0)
+		atomic.StoreInt32(&notModified, 0)
+
+		client := NewHttpClient(HttpClientOptionWithCache(cache))
+
+		for i := 0; i < 3; i++ {
+			resp, err := client.Get(ts.URL+"/fresh", nil)
+			if err != nil || resp.ToString() != "body of /fresh" {
+				t.Fatalf("Unexpected response. (%v)", err)
+			}
+
+			resp, err = client.Get(ts.URL+"/revalidate", nil)
+			if err != nil || resp.ToString() != "body of /revalidate" || resp.StatusCode != http.StatusOK {
+				t.Fatalf("Unexpected response. (%v)", err)
+			}
+		}
+
+		// /fresh 仅请求 1 次, /revalidate 请求 3 次 (其中 2 次返回 304)
+		if h, n := atomic.LoadInt32(&hits), atomic.LoadInt32(&notModified); h != 4 || n != 2 {
+			t.Errorf("Unexpected cache behavior. (hits: %d, 304: %d)", h, n)
+		}
+	}
+}
+
+func TestHttpClient_CacheCredentials(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	client := NewHttpClient(HttpClientOptionWithCache(NewHttpMemoryCache(8)))
+
+	// 携带认证信息的请求不使用缓存, 不同用户不会读取到彼此的响应
+	for _, token := range []string{"alice", "bob"} {
+		resp, err := client.Get(ts.URL+"/user", &HttpRequest{Auth: &HttpBearerAuth{Token: token}})
+		if err != nil || resp.ToString() != "Bearer "+token {
+			t.Errorf("Unexpected response. (%v)", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get(ts.URL+"/private", nil); err != nil {
+			t.Errorf("Request errors. (%v)", err)
+		}
+	}
+
+	if h := atomic.LoadInt32(&hits); h != 4 {
+		t.Errorf("Unexpected cache behavior. (hits: %d)", h)
+	}
+}
+
+func TestHttpMemoryCache_LRU(t *testing.T) {
+	cache := NewHttpMemoryCache(2)
+	cache.Set("a", &HttpCacheEntry{Response: &HttpResponse{}})
+	cache.Set("b", &HttpCacheEntry{Response: &HttpResponse{}})
+	cache.Get("a")
+	cache.Set("c", &HttpCacheEntry{Response: &HttpResponse{}})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("The least recently used entry should be evicted.")
+	}
+
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("The recently used entry should be kept.")
+	}
+}
@@ -0,0 +1,425 @@
+package goutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// CassetteMode 录制/回放模式。
+type CassetteMode int
+
+const (
+	// CassetteModeReplay 仅回放已录制的请求, 未匹配时返回错误
+	CassetteModeReplay CassetteMode = iota
+	// CassetteModeRecord 发送真实请求并录制 (覆盖原有记录)
+	CassetteModeRecord
+	// CassetteModeAuto 优先回放, 未匹配时发送真实请求并追加录制
+	CassetteModeAuto
+)
+
+// CassetteInteraction 录制的请求/响应对。
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request" yaml:"request"`
+	Response CassetteResponse `json:"response" yaml:"response"`
+}
+
+type CassetteRequest struct {
+	Method       string      `json:"method" yaml:"method"`
+	URL          string      `json:"url" yaml:"url"`
+	Headers      http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode   int         `json:"status_code" yaml:"status_code"`
+	Headers      http.Header `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Body         string      `json:"body,omitempty" yaml:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty" yaml:"body_encoding,omitempty"`
+}
+
+// CassetteMatcher 检查请求是否匹配录制的记录。(req 的 URL、请求头、请求体均已脱敏)
+type CassetteMatcher func(req *CassetteRequest, recorded *CassetteRequest) bool
+
+// CassetteMatchMethod 匹配请求方法。
+func CassetteMatchMethod(req *CassetteRequest, recorded *CassetteRequest) bool {
+	return strings.EqualFold(req.Method, recorded.Method)
+}
+
+// CassetteMatchURL 匹配完整 URL。(查询参数顺序无关)
+func CassetteMatchURL(req *CassetteRequest, recorded *CassetteRequest) bool {
+	u1, err1 := url.Parse(req.URL)
+	u2, err2 := url.Parse(recorded.URL)
+
+	if err1 != nil || err2 != nil {
+		return req.URL == recorded.URL
+	}
+
+	return u1.Scheme == u2.Scheme && u1.Host == u2.Host && u1.Path == u2.Path && u1.Query().Encode() == u2.Query().Encode()
+}
+
+// CassetteMatchBody 匹配请求体。
+func CassetteMatchBody(req *CassetteRequest, recorded *CassetteRequest) bool {
+	return req.Body == recorded.Body
+}
+
+// CassetteMatchHeaders 匹配指定的请求头。
+func CassetteMatchHeaders(names ...string) CassetteMatcher {
+	return func(req *CassetteRequest, recorded *CassetteRequest) bool {
+		for _, name := range names {
+			if strings.Join(req.Headers.Values(name), ",") != strings.Join(recorded.Headers.Values(name), ",") {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Cassette HTTP 请求录制/回放。可作为 http.RoundTripper 或 HttpClient 中间件使用。
+type Cassette struct {
+	// 录制文件路径 (扩展名为 .yaml/.yml 时使用 YAML 格式, 否则使用 JSON 格式)
+	Filename string
+	Mode     CassetteMode
+	// 匹配规则 (默认值: 请求方法 + URL)
+	Matchers []CassetteMatcher
+	// 需要隐藏的请求/响应头 (默认隐藏 Authorization, Cookie 等敏感请求头)
+	RedactHeaders []string
+	// 需要隐藏的查询参数
+	RedactQuery []string
+	// 敏感信息替换 (占位符 => 敏感值)，录制时将 URL、请求头、请求体及响应体中的敏感值替换为占位符
+	Secrets map[string]string
+	// 录制时发送真实请求使用的 RoundTripper (默认值: 共享连接池)
+	Transport http.RoundTripper
+
+	Interactions []*CassetteInteraction
+
+	mu   sync.Mutex
+	used map[int]bool
+}
+
+// NewCassette 创建录制/回放对象。回放模式下自动加载录制文件。
+func NewCassette(filename string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{Filename: filename, Mode: mode}
+
+	if mode != CassetteModeRecord && IsFile(filename) {
+		if err := c.Load(); err != nil {
+			return nil, err
+		}
+	} else if mode == CassetteModeReplay {
+		return nil, errors.New(fmt.Sprintf("Cassette file not found. (%s)", filename))
+	}
+
+	return c, nil
+}
+
+// HttpClientOptionWithCassette 使用 Cassette 录制/回放 HttpClient 请求。
+func HttpClientOptionWithCassette(c *Cassette) HttpClientOption {
+	return HttpClientOptionWithMiddleware(c.Middleware())
+}
+
+func (c *Cassette) isYaml() bool {
+	ext := strings.ToLower(filepath.Ext(c.Filename))
+
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Load 加载录制文件。
+func (c *Cassette) Load() error {
+	b, err := ioutil.ReadFile(c.Filename)
+	if err != nil {
+		return err
+	}
+
+	var interactions []*CassetteInteraction
+
+	if c.isYaml() {
+		err = yaml.Unmarshal(b, &interactions)
+	} else {
+		err = json.Unmarshal(b, &interactions)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.Interactions = interactions
+	c.used = nil
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Save 保存录制文件。
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		b   []byte
+		err error
+	)
+
+	if c.isYaml() {
+		b, err = yaml.Marshal(c.Interactions)
+	} else {
+		b, err = json.MarshalIndent(c.Interactions, "", "  ")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	dirname := filepath.Dir(c.Filename)
+
+	if !IsDir(dirname) {
+		if err = os.MkdirAll(dirname, 0755); err != nil {
+			return err
+		}
+	}
+
+	// 先写入临时文件再重命名, 避免中断时损坏已有的录制文件
+	tmp, err := ioutil.TempFile(dirname, ".cassette-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	tmp.Close()
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), c.Filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Middleware 返回 HttpClient 中间件。
+func (c *Cassette) Middleware() HttpMiddleware {
+	return func(next HttpRoundTripFunc) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return c.roundTrip(req, next)
+		}
+	}
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr := c.Transport
+	if tr == nil {
+		tr = defaultHttpTransport
+	}
+
+	return c.roundTrip(req, tr.RoundTrip)
+}
+
+func (c *Cassette) roundTrip(req *http.Request, next HttpRoundTripFunc) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		body = b
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	recorded := c.recordRequest(req, body)
+
+	if c.Mode != CassetteModeRecord {
+		if i := c.find(recorded); i != nil {
+			return c.replay(req, i)
+		}
+
+		if c.Mode == CassetteModeReplay {
+			return nil, errors.New(fmt.Sprintf("No recorded interaction matches the request. (%s %s)", recorded.Method, recorded.URL))
+		}
+	}
+
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	interaction := &CassetteInteraction{Request: *recorded}
+	interaction.Response.StatusCode = resp.StatusCode
+	interaction.Response.Headers = c.redactHeaders(resp.Header)
+	interaction.Response.Body, interaction.Response.BodyEncoding = c.encodeBody(b)
+
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, interaction)
+	if c.used == nil {
+		c.used = make(map[int]bool)
+	}
+	c.used[len(c.Interactions)-1] = true
+	c.mu.Unlock()
+
+	return resp, nil
+}
+
+// find 查找匹配的记录。优先返回未使用过的记录, 以支持相同请求的多次回放。
+func (c *Cassette) find(req *CassetteRequest) *CassetteInteraction {
+	matchers := c.Matchers
+	if len(matchers) == 0 {
+		matchers = []CassetteMatcher{CassetteMatchMethod, CassetteMatchURL}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.used == nil {
+		c.used = make(map[int]bool)
+	}
+
+	matched := -1
+
+	for i, interaction := range c.Interactions {
+		ok := true
+
+		for _, m := range matchers {
+			if !m(req, &interaction.Request) {
+				ok = false
+				break
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		if !c.used[i] {
+			c.used[i] = true
+			return interaction
+		}
+
+		if matched < 0 {
+			matched = i
+		}
+	}
+
+	if matched >= 0 {
+		return c.Interactions[matched]
+	}
+
+	return nil
+}
+
+func (c *Cassette) replay(req *http.Request, i *CassetteInteraction) (*http.Response, error) {
+	body, err := c.decodeBody(i.Response.Body, i.Response.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	header := i.Response.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		StatusCode:    i.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// recordRequest 生成脱敏后的请求记录。
+func (c *Cassette) recordRequest(req *http.Request, body []byte) *CassetteRequest {
+	u := *req.URL
+
+	if len(c.RedactQuery) > 0 {
+		q := u.Query()
+
+		for _, name := range c.RedactQuery {
+			if _, ok := q[name]; ok {
+				q.Set(name, "******")
+			}
+		}
+
+		u.RawQuery = q.Encode()
+	}
+
+	r := &CassetteRequest{
+		Method:  req.Method,
+		URL:     c.hideSecrets(u.String()),
+		Headers: c.redactHeaders(req.Header),
+	}
+
+	r.Body, r.BodyEncoding = c.encodeBody(body)
+
+	return r
+}
+
+func (c *Cassette) redactHeaders(header http.Header) http.Header {
+	header = redactHeader(header, append(append([]string{}, sensitiveHeaders...), c.RedactHeaders...))
+
+	for k, values := range header {
+		for i, v := range values {
+			values[i] = c.hideSecrets(v)
+		}
+		header[k] = values
+	}
+
+	return header
+}
+
+func (c *Cassette) hideSecrets(s string) string {
+	for placeholder, secret := range c.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, placeholder)
+		}
+	}
+
+	return s
+}
+
+// encodeBody 文本内容原样保存 (隐藏敏感信息), 二进制内容使用 Base64 编码。
+func (c *Cassette) encodeBody(b []byte) (string, string) {
+	if utf8.Valid(b) {
+		return c.hideSecrets(string(b)), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func (c *Cassette) decodeBody(s, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(s)
+	}
+
+	return []byte(s), nil
+}
@@ -0,0 +1,111 @@
+package goutils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette_RecordReplay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Method + " " + r.URL.Query().Get("q") + " " + string(b)))
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "cassette.yaml")
+
+	c, err := NewCassette(filename, CassetteModeRecord)
+	if err != nil {
+		t.Fatalf("Load errors. (%v)", err)
+	}
+	c.Secrets = map[string]string{"<SECRET>": "s3cr3t"}
+	c.RedactQuery = []string{"token"}
+
+	client := NewHttpClient(HttpClientOptionWithCassette(c))
+
+	resp, err := client.Post(ts.URL+"/echo?q=1&token=abc", &HttpRequest{
+		Headers: map[string]interface{}{"Authorization": "Bearer xyz"},
+		Text:    "hello s3cr3t",
+	})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+	if string(resp.Body) != "POST 1 hello s3cr3t" {
+		t.Fatalf("Unexpected response. (%s)", resp.Body)
+	}
+
+	if err = c.Save(); err != nil {
+		t.Fatalf("Save errors. (%v)", err)
+	}
+
+	// 回放时不再访问服务器
+	ts.Close()
+
+	b, _ := ioutil.ReadFile(filename)
+	for _, s := range []string{"s3cr3t", "xyz", "token=abc"} {
+		if strings.Contains(string(b), s) {
+			t.Errorf("The cassette contains a secret. (%s)", s)
+		}
+	}
+
+	c, err = NewCassette(filename, CassetteModeReplay)
+	if err != nil {
+		t.Fatalf("Load errors. (%v)", err)
+	}
+	c.Secrets = map[string]string{"<SECRET>": "s3cr3t"}
+	c.RedactQuery = []string{"token"}
+	c.Matchers = []CassetteMatcher{CassetteMatchMethod, CassetteMatchURL, CassetteMatchBody}
+
+	client = NewHttpClient(HttpClientOptionWithCassette(c))
+
+	resp, err = client.Post(ts.URL+"/echo?token=abc&q=1", &HttpRequest{Text: "hello s3cr3t"})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+	if resp.StatusCode != 200 || !strings.HasPrefix(string(resp.Body), "POST 1 hello") {
+		t.Errorf("Unexpected response. (%d, %s)", resp.StatusCode, resp.Body)
+	}
+
+	if _, err = client.Post(ts.URL+"/echo?q=1", &HttpRequest{Text: "other"}); err == nil {
+		t.Errorf("Expected an error for the unmatched request.")
+	}
+}
+
+func TestCassette_BotSender(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "wxwork.json")
+
+	c := &Cassette{
+		Filename: filename,
+		Mode:     CassetteModeReplay,
+		Secrets:  map[string]string{"WXWORK_TOKEN": "token-123"},
+		Interactions: []*CassetteInteraction{{
+			Request: CassetteRequest{Method: "POST", URL: "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=WXWORK_TOKEN"},
+			Response: CassetteResponse{
+				StatusCode: 200,
+				Headers:    http.Header{"Content-Type": {"application/json"}},
+				Body:       `{"errcode":0,"errmsg":"ok"}`,
+			},
+		}},
+	}
+
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save errors. (%v)", err)
+	}
+
+	c, err := NewCassette(filename, CassetteModeReplay)
+	if err != nil {
+		t.Fatalf("Load errors. (%v)", err)
+	}
+	c.Secrets = map[string]string{"WXWORK_TOKEN": "token-123"}
+
+	sender := &WxWorkBotSender{AccessToken: "token-123", Client: NewHttpClient(HttpClientOptionWithCassette(c))}
+
+	if err = sender.Send(NewWxWorkTextMessage("hello")); err != nil {
+		t.Errorf("Request errors. (%v)", err)
+	}
+}
@@ -0,0 +1,148 @@
+package goutils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const acceptEncoding = "gzip, deflate, br"
+
+// HttpClientOptionWithRequestCompression 使用 gzip 压缩超过 minSize 字节的 JSON/XML 请求体。(需服务器支持 Content-Encoding: gzip 请求)
+func HttpClientOptionWithRequestCompression(minSize int) HttpClientOption {
+	return func(c *HttpClient) {
+		c.CompressMinSize = minSize
+	}
+}
+
+// compressRequestBody 按 CompressMinSize 阈值压缩请求体。
+func (h *HttpClient) compressRequestBody(req *http.Request) error {
+	if h.CompressMinSize <= 0 || req.Body == nil || req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	if len(b) >= h.CompressMinSize {
+		var buf bytes.Buffer
+
+		w := gzip.NewWriter(&buf)
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+
+		b = buf.Bytes()
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	req.ContentLength = int64(len(b))
+
+	return nil
+}
+
+// decodeResponseBody 按 Content-Encoding 响应头解码响应体。(支持 gzip, deflate, br, 不支持的格式保持原样)
+func decodeResponseBody(resp *http.Response) {
+	encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+
+	for i := range encodings {
+		encodings[i] = strings.ToLower(strings.TrimSpace(encodings[i]))
+		if encodings[i] == "identity" {
+			encodings[i] = ""
+		}
+	}
+
+	if strings.Join(encodings, "") == "" {
+		return
+	}
+
+	for _, v := range encodings {
+		switch v {
+		case "", "gzip", "x-gzip", "deflate", "br":
+		default:
+			return
+		}
+	}
+
+	body := resp.Body
+
+	// 按编码顺序的逆序依次解码
+	for i := len(encodings) - 1; i >= 0; i-- {
+		if encodings[i] != "" {
+			body = &decodingReader{body: body, encoding: encodings[i]}
+		}
+	}
+
+	resp.Body = body
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodingReader 首次读取时才创建解码器, 避免 HEAD 等空响应体报错。
+type decodingReader struct {
+	body     io.ReadCloser
+	encoding string
+	r        io.Reader
+	err      error
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = newDecoder(d.body, d.encoding)
+	}
+
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	return d.r.Read(p)
+}
+
+func (d *decodingReader) Close() error {
+	if c, ok := d.r.(io.Closer); ok {
+		c.Close()
+	}
+
+	return d.body.Close()
+}
+
+func newDecoder(r io.Reader, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "br":
+		return brotli.NewReader(r), nil
+	case "deflate":
+		// HTTP deflate 应为 zlib 格式, 部分服务器直接返回 raw deflate 数据
+		br := bufio.NewReader(r)
+
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(br)
+		}
+
+		return flate.NewReader(br), nil
+	}
+
+	return r, nil
+}
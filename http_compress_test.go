@@ -0,0 +1,95 @@
+package goutils
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHttpClient_DecodeResponse(t *testing.T) {
+	payload := `{"foo":"bar"}`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.URL.Query().Get("encoding")
+
+		var buf bytes.Buffer
+		var wc io.WriteCloser
+
+		switch encoding {
+		case "gzip":
+			wc = gzip.NewWriter(&buf)
+		case "deflate":
+			wc = zlib.NewWriter(&buf)
+		case "raw-deflate":
+			wc, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+			encoding = "deflate"
+		case "br":
+			wc = brotli.NewWriter(&buf)
+		}
+
+		wc.Write([]byte(payload))
+		wc.Close()
+
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	client := NewHttpClient()
+
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "br"} {
+		resp, err := client.Get(ts.URL, &HttpRequest{Query: "encoding=" + encoding})
+		if err != nil {
+			t.Fatalf("Request errors. (%v)", err)
+		}
+
+		var v map[string]string
+		if err = resp.ToJson(&v); err != nil || v["foo"] != "bar" {
+			t.Errorf("Decode error. (%s: %v, %s)", encoding, err, resp.ToString())
+		}
+	}
+}
+
+func TestHttpClient_CompressRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+
+		b, _ := ioutil.ReadAll(body)
+		w.Write([]byte(r.Header.Get("Content-Encoding") + "|" + string(b)))
+	}))
+	defer ts.Close()
+
+	client := NewHttpClient(HttpClientOptionWithRequestCompression(64))
+
+	large := strings.Repeat("x", 128)
+
+	resp, err := client.Post(ts.URL, &HttpRequest{JSON: map[string]string{"data": large}})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if resp.ToString() != `gzip|{"data":"`+large+`"}` {
+		t.Errorf("Unexpected response. (%s)", resp.ToString())
+	}
+
+	resp, err = client.Post(ts.URL, &HttpRequest{JSON: map[string]string{"data": "small"}})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if resp.ToString() != `|{"data":"small"}` {
+		t.Errorf("Unexpected response. (%s)", resp.ToString())
+	}
+}
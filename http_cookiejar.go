@@ -0,0 +1,533 @@
+package goutils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/net/publicsuffix"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJarFormat Cookie 文件格式。
+type CookieJarFormat int
+
+const (
+	// CookieJarFormatAuto 按文件扩展名选择 (.json 为 JSON 格式, 其它为 Netscape 格式)
+	CookieJarFormatAuto CookieJarFormat = iota
+	// CookieJarFormatNetscape Netscape cookies.txt 格式 (兼容 curl/wget)
+	CookieJarFormatNetscape
+	// CookieJarFormatJSON JSON 格式
+	CookieJarFormatJSON
+)
+
+// CookieEntry Cookie 存储项。
+type CookieEntry struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+	// 是否仅匹配设置该 Cookie 的主机 (不包含子域名)？
+	HostOnly bool      `json:"host_only,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+func (e *CookieEntry) key() string {
+	return e.Domain + ";" + e.Path + ";" + e.Name
+}
+
+// persistent 是否为持久化 Cookie？(会话 Cookie 无过期时间)
+func (e *CookieEntry) persistent() bool {
+	return !e.Expires.IsZero()
+}
+
+func (e *CookieEntry) expired(now time.Time) bool {
+	return e.persistent() && !e.Expires.After(now)
+}
+
+// FileCookieJar 可保存至本地文件的 CookieJar。(实现 http.CookieJar 接口, 可用于 HttpRequest.CookieJar)
+//
+// 按公共后缀列表校验 Domain 属性, 拒绝为 com、co.uk 等公共后缀设置 Cookie。
+type FileCookieJar struct {
+	// 文件路径
+	Filename string
+	// 文件格式 (默认值: 按扩展名选择)
+	Format CookieJarFormat
+	// 是否保存会话 Cookie (无过期时间)？
+	SaveSessionCookies bool
+
+	mu      sync.Mutex
+	entries map[string]*CookieEntry
+}
+
+// NewFileCookieJar 创建 CookieJar, 文件存在时自动加载。
+func NewFileCookieJar(filename string) (*FileCookieJar, error) {
+	jar := &FileCookieJar{Filename: filename, entries: make(map[string]*CookieEntry)}
+
+	if IsFile(filename) {
+		if err := jar.Load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return jar, nil
+}
+
+func (j *FileCookieJar) format() CookieJarFormat {
+	if j.Format != CookieJarFormatAuto {
+		return j.Format
+	}
+
+	if strings.ToLower(filepath.Ext(j.Filename)) == ".json" {
+		return CookieJarFormatJSON
+	}
+
+	return CookieJarFormatNetscape
+}
+
+// SetCookies 实现 http.CookieJar 接口。
+func (j *FileCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host, err := canonicalCookieHost(u.Host)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.entries == nil {
+		j.entries = make(map[string]*CookieEntry)
+	}
+
+	for i, c := range cookies {
+		e, ok := newCookieEntry(host, u, c, now)
+		if !ok {
+			continue
+		}
+
+		// 同一批 Cookie 的创建时间依次递增, 保证按设置顺序返回
+		e.Created = now.Add(time.Duration(i))
+
+		key := e.key()
+
+		// Max-Age<0 或已过期表示删除
+		if e.expired(now) {
+			delete(j.entries, key)
+			continue
+		}
+
+		if old, ok := j.entries[key]; ok {
+			e.Created = old.Created
+		}
+
+		j.entries[key] = e
+	}
+}
+
+// Cookies 实现 http.CookieJar 接口。
+func (j *FileCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host, err := canonicalCookieHost(u.Host)
+	if err != nil {
+		return nil
+	}
+
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var selected []*CookieEntry
+
+	for key, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, key)
+			continue
+		}
+
+		if e.Secure && !secure {
+			continue
+		}
+
+		if e.HostOnly && host != e.Domain || !e.HostOnly && !cookieDomainMatch(host, e.Domain) {
+			continue
+		}
+
+		if !cookiePathMatch(path, e.Path) {
+			continue
+		}
+
+		selected = append(selected, e)
+	}
+
+	// 按 RFC 6265: 路径较长的优先, 其次按创建时间排序
+	sort.Slice(selected, func(a, b int) bool {
+		if len(selected[a].Path) != len(selected[b].Path) {
+			return len(selected[a].Path) > len(selected[b].Path)
+		}
+		return selected[a].Created.Before(selected[b].Created)
+	})
+
+	cookies := make([]*http.Cookie, len(selected))
+	for i, e := range selected {
+		cookies[i] = &http.Cookie{Name: e.Name, Value: e.Value}
+	}
+
+	return cookies
+}
+
+// Entries 返回所有未过期的 Cookie。
+func (j *FileCookieJar) Entries() []*CookieEntry {
+	j.Prune()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]*CookieEntry, 0, len(j.entries))
+	for _, e := range j.entries {
+		v := *e
+		entries = append(entries, &v)
+	}
+
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].key() < entries[b].key()
+	})
+
+	return entries
+}
+
+// Prune 删除已过期的 Cookie。
+func (j *FileCookieJar) Prune() {
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for key, e := range j.entries {
+		if e.expired(now) {
+			delete(j.entries, key)
+		}
+	}
+}
+
+// Clear 清空所有 Cookie。
+func (j *FileCookieJar) Clear() {
+	j.mu.Lock()
+	j.entries = make(map[string]*CookieEntry)
+	j.mu.Unlock()
+}
+
+// Load 从文件加载 Cookie (合并至当前 Cookie, 忽略已过期的 Cookie)。
+func (j *FileCookieJar) Load() error {
+	b, err := ioutil.ReadFile(j.Filename)
+	if err != nil {
+		return err
+	}
+
+	var entries []*CookieEntry
+
+	if j.format() == CookieJarFormatJSON {
+		err = json.Unmarshal(b, &entries)
+	} else {
+		entries, err = parseNetscapeCookies(b)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("Failed to load cookies. (%s)", j.Filename))
+	}
+
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.entries == nil {
+		j.entries = make(map[string]*CookieEntry)
+	}
+
+	for _, e := range entries {
+		if e.Name == "" || e.Domain == "" || e.expired(now) {
+			continue
+		}
+
+		if e.Path == "" {
+			e.Path = "/"
+		}
+
+		if e.Created.IsZero() {
+			e.Created = now
+		}
+
+		j.entries[e.key()] = e
+	}
+
+	return nil
+}
+
+// Save 保存 Cookie 至文件。(先写入临时文件再重命名, 避免中断时损坏原文件)
+func (j *FileCookieJar) Save() error {
+	var entries []*CookieEntry
+
+	for _, e := range j.Entries() {
+		if e.persistent() || j.SaveSessionCookies {
+			entries = append(entries, e)
+		}
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+
+	if j.format() == CookieJarFormatJSON {
+		if entries == nil {
+			entries = []*CookieEntry{}
+		}
+		b, err = json.MarshalIndent(entries, "", "  ")
+	} else {
+		b = formatNetscapeCookies(entries)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	dirname := filepath.Dir(j.Filename)
+
+	if !IsDir(dirname) {
+		if err = os.MkdirAll(dirname, 0755); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := ioutil.TempFile(dirname, ".cookies-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	tmp.Close()
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// Cookie 可能包含登录凭证, 仅允许当前用户读写
+	os.Chmod(tmp.Name(), 0600)
+
+	if err = os.Rename(tmp.Name(), j.Filename); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// newCookieEntry 按 RFC 6265 校验并创建存储项。
+func newCookieEntry(host string, u *url.URL, c *http.Cookie, now time.Time) (*CookieEntry, bool) {
+	if c.Name == "" {
+		return nil, false
+	}
+
+	e := &CookieEntry{
+		Name:     c.Name,
+		Value:    c.Value,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		Created:  now,
+	}
+
+	// Domain 属性
+	domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+
+	switch {
+	case domain == "" || domain == host:
+		e.Domain, e.HostOnly = host, true
+	case net.ParseIP(host) != nil:
+		// IP 地址只能设置 host-only Cookie
+		return nil, false
+	case !cookieDomainMatch(host, domain):
+		return nil, false
+	default:
+		// 拒绝为公共后缀 (如 com, co.uk) 设置 Cookie
+		if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+			return nil, false
+		}
+		e.Domain = domain
+	}
+
+	// Path 属性
+	if strings.HasPrefix(c.Path, "/") {
+		e.Path = c.Path
+	} else {
+		e.Path = defaultCookiePath(u.Path)
+	}
+
+	// Max-Age 优先于 Expires
+	switch {
+	case c.MaxAge < 0:
+		e.Expires = time.Unix(1, 0)
+	case c.MaxAge > 0:
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		e.Expires = c.Expires
+	}
+
+	return e, true
+}
+
+func canonicalCookieHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+
+	if host == "" {
+		return "", errors.New("Empty cookie host.")
+	}
+
+	return host, nil
+}
+
+func cookieDomainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+func cookiePathMatch(path, cookiePath string) bool {
+	if path == cookiePath {
+		return true
+	}
+
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+
+	return strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+func defaultCookiePath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
+
+const netscapeHttpOnlyPrefix = "#HttpOnly_"
+
+// parseNetscapeCookies 解析 Netscape cookies.txt 格式:
+// domain, include_subdomains, path, secure, expires, name, value (以 Tab 分隔)
+func parseNetscapeCookies(b []byte) ([]*CookieEntry, error) {
+	var entries []*CookieEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, netscapeHttpOnlyPrefix)
+		if httpOnly {
+			line = line[len(netscapeHttpOnlyPrefix):]
+		}
+
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// 值为空的 Cookie
+			fields = append(fields, "")
+		}
+
+		if len(fields) != 7 {
+			return nil, errors.New(fmt.Sprintf("Invalid cookie line. (line: %d)", n))
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid cookie expiration time. (line: %d)", n))
+		}
+
+		e := &CookieEntry{
+			Domain:   strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+
+		if expires > 0 {
+			e.Expires = time.Unix(expires, 0)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+func formatNetscapeCookies(entries []*CookieEntry) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("# Netscape HTTP Cookie File\n\n")
+
+	bool2str := func(b bool) string {
+		if b {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+
+	for _, e := range entries {
+		domain := e.Domain
+		if !e.HostOnly {
+			domain = "." + domain
+		}
+
+		if e.HttpOnly {
+			domain = netscapeHttpOnlyPrefix + domain
+		}
+
+		var expires int64
+		if e.persistent() {
+			expires = e.Expires.Unix()
+		}
+
+		fmt.Fprintf(&buf, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, bool2str(!e.HostOnly), e.Path, bool2str(e.Secure), expires, e.Name, e.Value)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,144 @@
+package goutils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileCookieJar_Domain(t *testing.T) {
+	jar, err := NewFileCookieJar(filepath.Join(t.TempDir(), "cookies.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("https://www.example.co.uk/account/login")
+
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "site", Value: "2", Domain: ".example.co.uk", Path: "/"},
+		{Name: "suffix", Value: "3", Domain: "co.uk"},
+		{Name: "other", Value: "4", Domain: "other.co.uk"},
+		{Name: "secure", Value: "5", Path: "/", Secure: true},
+		{Name: "expired", Value: "6", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	names := func(rawurl string) string {
+		u, _ := url.Parse(rawurl)
+		var s []string
+		for _, c := range jar.Cookies(u) {
+			s = append(s, c.Name)
+		}
+		return strings.Join(s, ",")
+	}
+
+	if got := names("https://www.example.co.uk/account/x"); got != "host,site,secure" {
+		t.Errorf("unexpected cookies: %s", got)
+	}
+	if got := names("http://api.example.co.uk/"); got != "site" {
+		t.Errorf("unexpected cookies for subdomain: %s", got)
+	}
+	if got := names("https://evil.co.uk/"); got != "" {
+		t.Errorf("public suffix cookie leaked: %s", got)
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "site", Domain: "example.co.uk", Path: "/", MaxAge: -1}})
+	if got := names("http://api.example.co.uk/"); got != "" {
+		t.Errorf("cookie not deleted: %s", got)
+	}
+}
+
+func TestFileCookieJar_Save(t *testing.T) {
+	for _, name := range []string{"cookies.txt", "cookies.json"} {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/login" {
+					http.SetCookie(w, &http.Cookie{Name: "token", Value: "abc", Path: "/", MaxAge: 3600, HttpOnly: true})
+					http.SetCookie(w, &http.Cookie{Name: "session", Value: "tmp", Path: "/"})
+					return
+				}
+
+				c, err := r.Cookie("token")
+				if err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte(c.Value))
+			}))
+			defer ts.Close()
+
+			filename := filepath.Join(t.TempDir(), name)
+
+			jar, err := NewFileCookieJar(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := NewHttpClient()
+
+			if _, err = client.Get(ts.URL+"/login", &HttpRequest{CookieJar: jar}); err != nil {
+				t.Fatal(err)
+			}
+			if err = jar.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			fi, err := os.Stat(filename)
+			if err != nil || fi.Mode().Perm() != 0600 {
+				t.Errorf("unexpected file mode: %v %v", fi, err)
+			}
+
+			jar, err = NewFileCookieJar(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries := jar.Entries()
+			if len(entries) != 1 || entries[0].Name != "token" || !entries[0].HttpOnly || !entries[0].HostOnly {
+				t.Fatalf("unexpected entries: %+v", entries)
+			}
+
+			resp, err := client.Get(ts.URL+"/me", &HttpRequest{CookieJar: jar})
+			if err != nil || string(resp.Body) != "abc" {
+				t.Errorf("cookie not restored: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseNetscapeCookies(t *testing.T) {
+	data := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tTRUE\t4102444800\tid\t42\n" +
+		"#HttpOnly_example.org\tFALSE\t/app\tFALSE\t0\tsid\t\n" +
+		"example.net\tFALSE\t/\tFALSE\t1\told\tx\n"
+
+	filename := filepath.Join(t.TempDir(), "cookies.txt")
+	ioutil.WriteFile(filename, []byte(data), 0600)
+
+	jar, err := NewFileCookieJar(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := jar.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	if e := entries[0]; e.Domain != "example.com" || e.HostOnly || !e.Secure || e.Expires.Unix() != 4102444800 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e := entries[1]; e.Domain != "example.org" || !e.HostOnly || !e.HttpOnly || e.Path != "/app" || e.persistent() {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	if _, err = parseNetscapeCookies([]byte("bad line\n")); err == nil {
+		t.Error("expected error for invalid line")
+	}
+}
@@ -0,0 +1,442 @@
+package goutils
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 分段下载时每个分段的最小字节数
+const minDownloadSegmentSize = 1024 * 1024
+
+// ChecksumMismatchError 下载文件哈希校验失败。
+type ChecksumMismatchError struct {
+	Filename  string
+	Algorithm HashAlgorithm
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Checksum mismatch. (%s, %s expected: %s, actual: %s)", e.Filename, e.Algorithm, e.Expected, e.Actual)
+}
+
+func checksumAlgorithm(r *HttpRequest) HashAlgorithm {
+	if r.ChecksumAlgorithm == "" {
+		return Sha256
+	}
+
+	return r.ChecksumAlgorithm
+}
+
+// verifyChecksum 比较实际哈希值与期望值, 不一致时删除临时文件。
+func verifyChecksum(r *HttpRequest, tmpname, actual string) error {
+	if strings.EqualFold(actual, r.Checksum) {
+		return nil
+	}
+
+	os.Remove(tmpname)
+	os.Remove(tmpname + ".validator")
+
+	return &ChecksumMismatchError{
+		Filename:  r.ToFile,
+		Algorithm: checksumAlgorithm(r),
+		Expected:  r.Checksum,
+		Actual:    actual,
+	}
+}
+
+// download 下载响应内容至 r.ToFile 文件。
+func (h *HttpClient) download(ctx context.Context, client *http.Client, method, uri string, r *HttpRequest) (*HttpResponse, error) {
+	if r.Checksum != "" {
+		if _, err := newHash(checksumAlgorithm(r)); err != nil {
+			return nil, err
+		}
+	}
+
+	dirn := filepath.Dir(r.ToFile)
+
+	if !IsDir(dirn) {
+		if err := os.MkdirAll(dirn, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Connections > 1 && strings.ToUpper(method) == "GET" {
+		if r.Resume {
+			return nil, errors.New("Resume is not supported for parallel downloads. (Connections > 1)")
+		}
+
+		// 多个分段请求共用一个 trace, 耗时明细没有意义
+		if r.Trace {
+			return nil, errors.New("Trace is not supported for parallel downloads. (Connections > 1)")
+		}
+
+		resp, ok, err := h.downloadParallel(ctx, client, uri, r)
+		if err != nil || ok {
+			return resp, err
+		}
+	}
+
+	return h.downloadStream(ctx, client, method, uri, r)
+}
+
+// downloadStream 单连接下载。开启 Resume 时从已存在的 .tmp 文件末尾继续下载。
+func (h *HttpClient) downloadStream(ctx context.Context, client *http.Client, method, uri string, r *HttpRequest) (*HttpResponse, error) {
+	filename := r.ToFile
+	tmpname := filename + ".tmp"
+	metaname := tmpname + ".validator"
+
+	var offset int64
+
+	rr := r
+
+	if r.Resume {
+		if info, err := os.Stat(tmpname); err == nil && info.Size() > 0 {
+			if b, err := ioutil.ReadFile(metaname); err == nil && len(b) > 0 {
+				offset = info.Size()
+				rr = withRequestHeaders(r, map[string]string{
+					"Range":    fmt.Sprintf("bytes=%d-", offset),
+					"If-Range": string(b),
+				})
+			}
+		}
+	}
+
+	resp, err := h.do(ctx, client, method, uri, rr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	// 本地临时文件与服务器内容不匹配, 重新下载
+	if offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		resp.Body.Close()
+		os.Remove(tmpname)
+		os.Remove(metaname)
+
+		return h.downloadStream(ctx, client, method, uri, r)
+	}
+
+	if err = checkResponseStatus(resp, r); err != nil {
+		return nil, err
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if offset > 0 && resp.StatusCode == http.StatusPartialContent {
+		flag = os.O_WRONLY | os.O_APPEND
+	} else {
+		offset = 0
+	}
+
+	if r.Resume {
+		if v := rangeValidator(resp.Header); v != "" && offset == 0 {
+			_ = ioutil.WriteFile(metaname, []byte(v), 0644)
+		}
+	}
+
+	out, err := os.OpenFile(tmpname, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// 此处不能使用 defer 方式关闭 out 资源，因为在 os.Rename 时资源句柄未释放造成重命名出错！
+	var dst io.Writer = out
+	var hasher hash.Hash
+
+	if r.Checksum != "" {
+		hasher, _ = newHash(checksumAlgorithm(r))
+		dst = io.MultiWriter(out, hasher)
+
+		// 断点续传时需先计算已下载部分的哈希值
+		if offset > 0 {
+			if err = hashFile(hasher, tmpname); err != nil {
+				out.Close()
+				return nil, err
+			}
+		}
+	}
+
+	var totalSize uint64
+
+	if resp.ContentLength > 0 {
+		totalSize = uint64(resp.ContentLength)
+	}
+
+	w, done := h.progressWriter(r, totalSize)
+
+	_, err = io.Copy(dst, io.TeeReader(resp.Body, w))
+
+	done()
+	out.Close()
+
+	if err != nil {
+		if !r.Resume {
+			os.Remove(tmpname)
+		}
+
+		return nil, err
+	}
+
+	if hasher != nil {
+		if err = verifyChecksum(r, tmpname, hex.EncodeToString(hasher.Sum(nil))); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = os.Rename(tmpname, filename); err != nil {
+		return nil, err
+	}
+
+	os.Remove(metaname)
+
+	return newHttpResponse(resp, nil), nil
+}
+
+// downloadParallel 分段并发下载。服务器不支持 Range 请求时返回 ok = false, 由调用方回退至单连接下载。
+func (h *HttpClient) downloadParallel(ctx context.Context, client *http.Client, uri string, r *HttpRequest) (ret *HttpResponse, ok bool, err error) {
+	probe, err := h.do(ctx, client, "GET", uri, withRequestHeaders(r, map[string]string{"Range": "bytes=0-0"}))
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 服务器忽略 Range 时返回完整内容, 直接关闭连接而不读取
+	if probe.StatusCode != http.StatusPartialContent {
+		probe.Body.Close()
+		return nil, false, nil
+	}
+
+	io.Copy(ioutil.Discard, io.LimitReader(probe.Body, 1))
+	probe.Body.Close()
+
+	size := contentRangeSize(probe.Header.Get("Content-Range"))
+
+	if size <= 0 {
+		return nil, false, nil
+	}
+
+	n := int64(r.Connections)
+
+	if size/n < minDownloadSegmentSize {
+		n = size / minDownloadSegmentSize
+	}
+
+	if n <= 1 {
+		return nil, false, nil
+	}
+
+	validator := rangeValidator(probe.Header)
+	tmpname := r.ToFile + ".tmp"
+
+	out, err := os.Create(tmpname)
+	if err != nil {
+		return nil, true, err
+	}
+
+	if err = out.Truncate(size); err != nil {
+		out.Close()
+		os.Remove(tmpname)
+		return nil, true, err
+	}
+
+	w, done := h.progressWriter(r, uint64(size))
+	pw := &syncWriter{w: w}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	segment := size / n
+
+	for i := int64(0); i < n; i++ {
+		start := i * segment
+		end := start + segment - 1
+
+		if i == n-1 {
+			end = size - 1
+		}
+
+		wg.Add(1)
+
+		go func(start, end int64) {
+			defer wg.Done()
+
+			if err := h.downloadSegment(ctx, client, uri, r, out, pw, start, end, validator); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+
+	wg.Wait()
+	done()
+	out.Close()
+
+	if firstErr != nil {
+		os.Remove(tmpname)
+		return nil, true, firstErr
+	}
+
+	if r.Checksum != "" {
+		actual, err := CheckSum(tmpname, checksumAlgorithm(r), false)
+		if err != nil {
+			os.Remove(tmpname)
+			return nil, true, err
+		}
+
+		if err = verifyChecksum(r, tmpname, actual); err != nil {
+			return nil, true, err
+		}
+	}
+
+	if err = os.Rename(tmpname, r.ToFile); err != nil {
+		return nil, true, err
+	}
+
+	ret = newHttpResponse(probe, nil)
+	ret.StatusCode = http.StatusOK
+	ret.ContentLength = size
+	ret.Header = probe.Header.Clone()
+	ret.Header.Del("Content-Range")
+	ret.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+
+	return ret, true, nil
+}
+
+// downloadSegment 下载 [start, end] 区间内容并写入 out 文件对应位置。
+func (h *HttpClient) downloadSegment(ctx context.Context, client *http.Client, uri string, r *HttpRequest, out *os.File, progress io.Writer, start, end int64, validator string) error {
+	headers := map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", start, end)}
+
+	if validator != "" {
+		headers["If-Range"] = validator
+	}
+
+	resp, err := h.do(ctx, client, "GET", uri, withRequestHeaders(r, headers))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return errors.New(fmt.Sprintf("The server did not honor the range request. (StatusCode: %d)", resp.StatusCode))
+	}
+
+	// 限制写入长度, 避免服务器返回多余内容时覆盖相邻分段
+	length := end - start + 1
+
+	written, err := io.Copy(&offsetWriter{f: out, offset: start}, io.TeeReader(io.LimitReader(resp.Body, length), progress))
+	if err != nil {
+		return err
+	}
+
+	if written != length {
+		return errors.New(fmt.Sprintf("Incomplete segment download. (bytes=%d-%d, written: %d)", start, end, written))
+	}
+
+	if n, _ := io.ReadFull(resp.Body, make([]byte, 1)); n > 0 {
+		return errors.New(fmt.Sprintf("The segment response is longer than requested. (bytes=%d-%d)", start, end))
+	}
+
+	return nil
+}
+
+// hashFile 将文件内容写入 hasher。
+func hashFile(hasher hash.Hash, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(hasher, f)
+
+	return err
+}
+
+// withRequestHeaders 复制 HttpRequest 并追加请求头。(不修改原对象)
+func withRequestHeaders(r *HttpRequest, headers map[string]string) *HttpRequest {
+	rr := *r
+	rr.Headers = make(map[string]interface{}, len(r.Headers)+len(headers))
+
+	for k, v := range r.Headers {
+		rr.Headers[k] = v
+	}
+
+	for k, v := range headers {
+		rr.Headers[k] = v
+	}
+
+	return &rr
+}
+
+// rangeValidator 读取可用于 If-Range 的校验值。(弱 ETag 不可用于 If-Range)
+func rangeValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return header.Get("Last-Modified")
+}
+
+// contentRangeSize 解析 Content-Range 响应头中的文件总大小。(例如: bytes 0-0/1024)
+func contentRangeSize(v string) int64 {
+	i := strings.LastIndex(v, "/")
+	if i < 0 {
+		return -1
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(v[i+1:]), 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return size
+}
+
+// offsetWriter 从指定偏移位置顺序写入文件。
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+
+	return n, err
+}
+
+// syncWriter 并发安全的 io.Writer 包装。
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.w.Write(p)
+}
@@ -0,0 +1,168 @@
+package goutils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRangeTestServer(content []byte, ranges *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(ranges, 1)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+}
+
+func TestHttpClient_DownloadParallel(t *testing.T) {
+	content := make([]byte, 3*minDownloadSegmentSize+123)
+	rand.Read(content)
+
+	var ranges int32
+
+	ts := newRangeTestServer(content, &ranges)
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "data.bin")
+
+	client := NewHttpClient()
+	if _, err := client.Get(ts.URL, &HttpRequest{ToFile: filename, Connections: 3}); err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	b, _ := ioutil.ReadFile(filename)
+	if !bytes.Equal(b, content) {
+		t.Errorf("Downloaded content mismatch.")
+	}
+
+	// 1 次探测请求 + 3 个分段请求
+	if n := atomic.LoadInt32(&ranges); n != 4 {
+		t.Errorf("Unexpected range requests. (%d)", n)
+	}
+}
+
+func TestHttpClient_DownloadParallelDump(t *testing.T) {
+	content := make([]byte, 4*minDownloadSegmentSize)
+	rand.Read(content)
+
+	var ranges int32
+
+	ts := newRangeTestServer(content, &ranges)
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "data.bin")
+
+	resp, err := NewHttpClient().Get(ts.URL, &HttpRequest{ToFile: filename, Connections: 4, Dump: true})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	// 探测请求及每个分段请求均被记录
+	if n := bytes.Count(resp.Dump, []byte("GET / HTTP/1.1")); n != 5 {
+		t.Errorf("Unexpected dumped requests. (%d)", n)
+	}
+}
+
+func TestHttpClient_DownloadParallelOverlong(t *testing.T) {
+	content := make([]byte, 2*minDownloadSegmentSize)
+	rand.Read(content)
+
+	// 分段响应比请求的区间多返回内容
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+
+		if end == 0 {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-0/%d", len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[:1])
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start:])
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "data.bin")
+
+	if _, err := NewHttpClient().Get(ts.URL, &HttpRequest{ToFile: filename, Connections: 2}); err == nil {
+		t.Errorf("The overlong segment response should be rejected.")
+	}
+
+	if _, err := NewHttpClient().Get(ts.URL, &HttpRequest{ToFile: filename, Connections: 2, Resume: true}); err == nil {
+		t.Errorf("Resume should not be supported for parallel downloads.")
+	}
+}
+
+func TestHttpClient_DownloadResume(t *testing.T) {
+	content := make([]byte, 64*1024)
+	rand.Read(content)
+
+	var ranges int32
+
+	ts := newRangeTestServer(content, &ranges)
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "data.bin")
+
+	ioutil.WriteFile(filename+".tmp", content[:1000], 0644)
+	ioutil.WriteFile(filename+".tmp.validator", []byte(`"v1"`), 0644)
+
+	client := NewHttpClient()
+	resp, err := client.Get(ts.URL, &HttpRequest{ToFile: filename, Resume: true})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("Expected a partial response. (%d)", resp.StatusCode)
+	}
+
+	b, _ := ioutil.ReadFile(filename)
+	if !bytes.Equal(b, content) {
+		t.Errorf("Resumed content mismatch.")
+	}
+
+	if IsFile(filename + ".tmp.validator") {
+		t.Errorf("The validator file was not removed.")
+	}
+}
+
+func TestHttpClient_DownloadChecksum(t *testing.T) {
+	content := []byte("checksum test content")
+
+	var ranges int32
+
+	ts := newRangeTestServer(content, &ranges)
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "data.txt")
+
+	client := NewHttpClient()
+	if _, err := client.Get(ts.URL, &HttpRequest{ToFile: filename, Checksum: SHA256(string(content))}); err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	_, err := client.Get(ts.URL, &HttpRequest{ToFile: filename + ".bad", Checksum: MD5("other"), ChecksumAlgorithm: Md5})
+
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a checksum mismatch error. (%v)", err)
+	}
+
+	if IsFile(filename+".bad") || IsFile(filename+".bad.tmp") {
+		t.Errorf("The mismatched file should not be kept.")
+	}
+}
@@ -0,0 +1,201 @@
+package goutils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// GraphQLClient GraphQL 客户端。
+type GraphQLClient struct {
+	// 接口地址
+	URL string
+	// HTTP 客户端 (默认值: NewHttpClient())
+	Client *HttpClient
+	// 公共请求头
+	Headers map[string]string
+	// 是否启用持久化查询 (Automatic Persisted Queries)？首次仅发送查询的 sha256 哈希值, 服务器未缓存时再发送完整查询
+	PersistedQueries bool
+}
+
+// NewGraphQLClient 创建 GraphQL 客户端。
+func NewGraphQLClient(url string, client *HttpClient) *GraphQLClient {
+	return &GraphQLClient{URL: url, Client: client}
+}
+
+// GraphQLRequest GraphQL 请求。
+type GraphQLRequest struct {
+	Query         string                 `json:"query,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLResponse GraphQL 响应。
+type GraphQLResponse struct {
+	Data       json.RawMessage        `json:"data,omitempty"`
+	Errors     []*GraphQLErrorItem    `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation 错误在查询语句中的位置。
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLErrorItem GraphQL 响应 errors 数组中的单个错误。
+type GraphQLErrorItem struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Code 返回 extensions.code 错误码。
+func (e *GraphQLErrorItem) Code() string {
+	if code, ok := e.Extensions["code"].(string); ok {
+		return code
+	}
+
+	return ""
+}
+
+func (e *GraphQLErrorItem) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+
+	return fmt.Sprintf("%s (path: %s)", e.Message, strings.Join(path, "."))
+}
+
+// GraphQLError GraphQL 响应包含 errors 时返回的错误。(可通过 errors.As 获取, Data 中可能包含部分结果)
+type GraphQLError struct {
+	// HTTP 响应状态码
+	StatusCode int
+	Errors     []*GraphQLErrorItem
+}
+
+func (e *GraphQLError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, item := range e.Errors {
+		messages[i] = item.Error()
+	}
+
+	return fmt.Sprintf("GraphQL request failed. (%s)", strings.Join(messages, "; "))
+}
+
+// Query 执行查询并将 data 解码至 v (v 为 nil 时忽略)。
+// 响应包含 errors 时返回 *GraphQLError, 同时仍会解码部分结果。
+func (c *GraphQLClient) Query(ctx context.Context, query string, variables map[string]interface{}, v interface{}) error {
+	_, err := c.Do(ctx, &GraphQLRequest{Query: query, Variables: variables}, v)
+
+	return err
+}
+
+// Do 发送 GraphQL 请求并将 data 解码至 v (v 为 nil 时忽略)。
+func (c *GraphQLClient) Do(ctx context.Context, req *GraphQLRequest, v interface{}) (*GraphQLResponse, error) {
+	var (
+		resp *GraphQLResponse
+		err  error
+	)
+
+	if c.PersistedQueries && req.Query != "" {
+		hash := sha256.Sum256([]byte(req.Query))
+
+		pq := *req
+		pq.Query = ""
+		pq.Extensions = map[string]interface{}{}
+		for k, val := range req.Extensions {
+			pq.Extensions[k] = val
+		}
+		pq.Extensions["persistedQuery"] = map[string]interface{}{
+			"version":    1,
+			"sha256Hash": hex.EncodeToString(hash[:]),
+		}
+
+		resp, err = c.post(ctx, &pq)
+
+		// 服务器未缓存该查询时, 携带完整查询重新发送以完成注册
+		if isPersistedQueryNotFound(resp) {
+			pq.Query = req.Query
+			resp, err = c.post(ctx, &pq)
+		}
+	} else {
+		resp, err = c.post(ctx, req)
+	}
+
+	if resp == nil {
+		return nil, err
+	}
+
+	if v != nil && len(resp.Data) > 0 && string(resp.Data) != "null" {
+		if err2 := json.Unmarshal(resp.Data, v); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+
+	return resp, err
+}
+
+// post 发送请求并解析响应。非 2xx 响应包含 errors 时返回 *GraphQLError, 否则返回 *HttpError。
+func (c *GraphQLClient) post(ctx context.Context, req *GraphQLRequest) (*GraphQLResponse, error) {
+	client := c.Client
+	if client == nil {
+		client = NewHttpClient()
+	}
+
+	headers := map[string]interface{}{"Accept": "application/json"}
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+
+	httpResp, err := client.PostContext(ctx, c.URL, &HttpRequest{Headers: headers, JSON: req})
+	if err != nil {
+		var he *HttpError
+		if !errors.As(err, &he) {
+			return nil, err
+		}
+
+		resp := &GraphQLResponse{}
+		if json.Unmarshal(he.Body, resp) != nil || len(resp.Errors) == 0 {
+			return nil, err
+		}
+
+		return resp, &GraphQLError{StatusCode: he.StatusCode, Errors: resp.Errors}
+	}
+
+	resp := &GraphQLResponse{}
+	if err = json.Unmarshal(httpResp.Body, resp); err != nil {
+		return nil, errors.Wrap(err, "Invalid GraphQL response")
+	}
+
+	if len(resp.Errors) > 0 {
+		return resp, &GraphQLError{StatusCode: httpResp.StatusCode, Errors: resp.Errors}
+	}
+
+	return resp, nil
+}
+
+func isPersistedQueryNotFound(resp *GraphQLResponse) bool {
+	if resp == nil {
+		return false
+	}
+
+	for _, e := range resp.Errors {
+		if e.Message == "PersistedQueryNotFound" || e.Code() == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+
+	return false
+}
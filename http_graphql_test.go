@@ -0,0 +1,111 @@
+package goutils
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestGraphQLClient_Query(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GraphQLRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch req.OperationName {
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Syntax Error","locations":[{"line":1,"column":3}],"extensions":{"code":"GRAPHQL_PARSE_FAILED"}}]}`))
+		case "partial":
+			w.Write([]byte(`{"data":{"user":{"name":"Alice"},"friends":null},"errors":[{"message":"forbidden","path":["friends",0]}]}`))
+		default:
+			w.Write([]byte(`{"data":{"user":{"name":"` + req.Variables["id"].(string) + `"}}}`))
+		}
+	}))
+	defer ts.Close()
+
+	c := NewGraphQLClient(ts.URL, nil)
+
+	var out struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+
+	if err := c.Query(context.Background(), `query($id: ID!) { user(id: $id) { name } }`, map[string]interface{}{"id": "u1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.User.Name != "u1" {
+		t.Errorf("unexpected data: %+v", out)
+	}
+
+	_, err := c.Do(context.Background(), &GraphQLRequest{Query: "{ user { name } friends }", OperationName: "partial"}, &out)
+	var ge *GraphQLError
+	if !errors.As(err, &ge) || len(ge.Errors) != 1 || ge.Errors[0].Error() != "forbidden (path: friends.0)" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if out.User.Name != "Alice" {
+		t.Errorf("partial data not decoded: %+v", out)
+	}
+
+	_, err = c.Do(context.Background(), &GraphQLRequest{Query: "{", OperationName: "bad"}, nil)
+	if !errors.As(err, &ge) || ge.StatusCode != 400 || ge.Errors[0].Code() != "GRAPHQL_PARSE_FAILED" || ge.Errors[0].Locations[0].Column != 3 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestGraphQLClient_PersistedQueries(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		stored  = map[string]string{}
+		queries []string
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req GraphQLRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		queries = append(queries, req.Query)
+		hash := req.Extensions["persistedQuery"].(map[string]interface{})["sha256Hash"].(string)
+
+		if req.Query != "" {
+			if SHA256(req.Query) != hash {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			stored[hash] = req.Query
+		}
+
+		if _, ok := stored[hash]; !ok {
+			w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`))
+			return
+		}
+
+		w.Write([]byte(`{"data":{"ok":true}}`))
+	}))
+	defer ts.Close()
+
+	c := &GraphQLClient{URL: ts.URL, PersistedQueries: true}
+
+	for i := 0; i < 2; i++ {
+		var out struct {
+			OK bool `json:"ok"`
+		}
+
+		if err := c.Query(context.Background(), "{ ok }", nil, &out); err != nil || !out.OK {
+			t.Fatalf("round %d: %v %+v", i, err, out)
+		}
+	}
+
+	if len(queries) != 3 || queries[0] != "" || queries[1] != "{ ok }" || queries[2] != "" {
+		t.Errorf("unexpected requests: %q", queries)
+	}
+}
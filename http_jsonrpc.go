@@ -0,0 +1,253 @@
+package goutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// JSONRPCClient JSON-RPC 2.0 客户端。(基于 HttpClient, 使用 HTTP POST 传输)
+type JSONRPCClient struct {
+	// 接口地址
+	URL string
+	// HTTP 客户端 (默认值: NewHttpClient())
+	Client *HttpClient
+	// 公共请求头
+	Headers map[string]string
+
+	id uint64
+}
+
+// NewJSONRPCClient 创建 JSON-RPC 2.0 客户端。
+func NewJSONRPCClient(url string, client *HttpClient) *JSONRPCClient {
+	return &JSONRPCClient{URL: url, Client: client}
+}
+
+// JSONRPCError JSON-RPC 错误对象。(可通过 errors.As 获取)
+type JSONRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error. (code: %d, message: %s)", e.Code, e.Message)
+}
+
+type jsonrpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      *uint64     `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type jsonrpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+// matches 检查响应 ID 是否与请求 ID 一致。(兼容以字符串形式返回数字 ID 的服务器)
+func (r *jsonrpcResponse) matches(id uint64) bool {
+	return strings.Trim(string(r.ID), `"`) == strconv.FormatUint(id, 10)
+}
+
+// decode 将结果解码至 result (result 为 nil 时忽略)。
+func (r *jsonrpcResponse) decode(result interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(r.Result, result)
+}
+
+func (c *JSONRPCClient) newRequest(method string, params interface{}, notify bool) *jsonrpcRequest {
+	req := &jsonrpcRequest{JSONRPC: "2.0", Method: method, Params: params}
+
+	if !notify {
+		id := atomic.AddUint64(&c.id, 1)
+		req.ID = &id
+	}
+
+	return req
+}
+
+// post 发送请求, 返回响应内容。非 2xx 响应包含错误对象时返回 *JSONRPCError, 否则返回 *HttpError。
+func (c *JSONRPCClient) post(ctx context.Context, v interface{}) ([]byte, error) {
+	client := c.Client
+	if client == nil {
+		client = NewHttpClient()
+	}
+
+	headers := map[string]interface{}{"Accept": "application/json"}
+	for k, v := range c.Headers {
+		headers[k] = v
+	}
+
+	resp, err := client.PostContext(ctx, c.URL, &HttpRequest{Headers: headers, JSON: v})
+	if err != nil {
+		var he *HttpError
+		if !errors.As(err, &he) {
+			return nil, err
+		}
+
+		r := &jsonrpcResponse{}
+		if json.Unmarshal(he.Body, r) != nil || r.Error == nil {
+			return nil, err
+		}
+
+		return nil, r.Error
+	}
+
+	return resp.Body, nil
+}
+
+// Call 调用远程方法并将结果解码至 result (result 为 nil 时忽略)。服务器返回错误对象时返回 *JSONRPCError。
+func (c *JSONRPCClient) Call(method string, params interface{}, result interface{}) error {
+	return c.CallContext(context.Background(), method, params, result)
+}
+
+func (c *JSONRPCClient) CallContext(ctx context.Context, method string, params interface{}, result interface{}) error {
+	req := c.newRequest(method, params, false)
+
+	body, err := c.post(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	resp := &jsonrpcResponse{}
+	if err = json.Unmarshal(body, resp); err != nil {
+		return errors.Wrap(err, "Invalid JSON-RPC response")
+	}
+
+	// 无法解析请求时服务器返回的 id 为 null
+	if resp.Error == nil && !resp.matches(*req.ID) {
+		return errors.New(fmt.Sprintf("The JSON-RPC response id does not match. (expected: %d, actual: %s)", *req.ID, resp.ID))
+	}
+
+	return resp.decode(result)
+}
+
+// Notify 发送通知 (不包含 id, 服务器不返回结果)。
+func (c *JSONRPCClient) Notify(method string, params interface{}) error {
+	return c.NotifyContext(context.Background(), method, params)
+}
+
+func (c *JSONRPCClient) NotifyContext(ctx context.Context, method string, params interface{}) error {
+	_, err := c.post(ctx, c.newRequest(method, params, true))
+
+	return err
+}
+
+// JSONRPCBatchCall 批量请求中的单个调用。
+type JSONRPCBatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	// 调用结果错误 (*JSONRPCError 或解码错误, SendContext 返回后有效)
+	Error error
+
+	request *jsonrpcRequest
+}
+
+// JSONRPCBatch 批量请求。
+type JSONRPCBatch struct {
+	client *JSONRPCClient
+	calls  []*JSONRPCBatchCall
+}
+
+// NewBatch 创建批量请求。
+func (c *JSONRPCClient) NewBatch() *JSONRPCBatch {
+	return &JSONRPCBatch{client: c}
+}
+
+// Add 添加方法调用, 结果将解码至 result。
+func (b *JSONRPCBatch) Add(method string, params interface{}, result interface{}) *JSONRPCBatchCall {
+	call := &JSONRPCBatchCall{Method: method, Params: params, Result: result}
+	call.request = b.client.newRequest(method, params, false)
+	b.calls = append(b.calls, call)
+
+	return call
+}
+
+// Notify 添加通知。
+func (b *JSONRPCBatch) Notify(method string, params interface{}) {
+	b.calls = append(b.calls, &JSONRPCBatchCall{Method: method, Params: params, request: b.client.newRequest(method, params, true)})
+}
+
+// Calls 返回已添加的调用。
+func (b *JSONRPCBatch) Calls() []*JSONRPCBatchCall {
+	return b.calls
+}
+
+// Send 发送批量请求。返回的 error 仅表示请求整体失败, 单个调用的错误记录在 JSONRPCBatchCall.Error 中。
+func (b *JSONRPCBatch) Send() error {
+	return b.SendContext(context.Background())
+}
+
+func (b *JSONRPCBatch) SendContext(ctx context.Context) error {
+	if len(b.calls) == 0 {
+		return nil
+	}
+
+	reqs := make([]*jsonrpcRequest, len(b.calls))
+	for i, call := range b.calls {
+		reqs[i] = call.request
+	}
+
+	body, err := b.client.post(ctx, reqs)
+	if err != nil {
+		return err
+	}
+
+	body = bytes.TrimSpace(body)
+
+	// 仅包含通知时服务器不返回内容
+	if len(body) == 0 {
+		return nil
+	}
+
+	// 整个批量请求无效时服务器返回单个错误对象
+	if body[0] == '{' {
+		resp := &jsonrpcResponse{}
+		if err = json.Unmarshal(body, resp); err != nil {
+			return errors.Wrap(err, "Invalid JSON-RPC response")
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+		return errors.New("Unexpected JSON-RPC batch response.")
+	}
+
+	var resps []*jsonrpcResponse
+	if err = json.Unmarshal(body, &resps); err != nil {
+		return errors.Wrap(err, "Invalid JSON-RPC response")
+	}
+
+	// 批量响应的顺序可能与请求不同, 按 id 关联
+	for _, call := range b.calls {
+		if call.request.ID == nil {
+			continue
+		}
+
+		call.Error = errors.New(fmt.Sprintf("No JSON-RPC response for the request. (id: %d, method: %s)", *call.request.ID, call.Method))
+
+		for _, resp := range resps {
+			if resp.matches(*call.request.ID) {
+				call.Error = resp.decode(call.Result)
+				break
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package goutils
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func jsonrpcTestHandle(req map[string]interface{}) map[string]interface{} {
+	id, ok := req["id"]
+	if !ok {
+		return nil
+	}
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+
+	switch req["method"] {
+	case "add":
+		params := req["params"].([]interface{})
+		resp["result"] = params[0].(float64) + params[1].(float64)
+	default:
+		resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found", "data": req["method"]}
+	}
+
+	return resp
+}
+
+func TestJSONRPCClient(t *testing.T) {
+	var notified int
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+
+		if b[0] == '[' {
+			var reqs []map[string]interface{}
+			json.Unmarshal(b, &reqs)
+
+			var resps []map[string]interface{}
+			// 逆序返回, 验证按 id 关联
+			for i := len(reqs) - 1; i >= 0; i-- {
+				if resp := jsonrpcTestHandle(reqs[i]); resp != nil {
+					resps = append(resps, resp)
+				} else {
+					notified++
+				}
+			}
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+
+		var req map[string]interface{}
+		json.Unmarshal(b, &req)
+
+		resp := jsonrpcTestHandle(req)
+		if resp == nil {
+			notified++
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer ts.Close()
+
+	c := NewJSONRPCClient(ts.URL, nil)
+
+	var sum int
+	if err := c.Call("add", []int{1, 2}, &sum); err != nil || sum != 3 {
+		t.Errorf("Call: %d, %v", sum, err)
+	}
+
+	err := c.Call("missing", nil, nil)
+	var re *JSONRPCError
+	if !errors.As(err, &re) || re.Code != -32601 || string(re.Data) != `"missing"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err = c.Notify("log", map[string]string{"msg": "hi"}); err != nil {
+		t.Error(err)
+	}
+
+	var a, b int
+	batch := c.NewBatch()
+	c1 := batch.Add("add", []int{1, 1}, &a)
+	c2 := batch.Add("add", []int{2, 3}, &b)
+	batch.Notify("log", nil)
+	c3 := batch.Add("missing", nil, nil)
+
+	if err = batch.Send(); err != nil {
+		t.Fatal(err)
+	}
+	if c1.Error != nil || c2.Error != nil || a != 2 || b != 5 {
+		t.Errorf("unexpected batch results: %d %d %v %v", a, b, c1.Error, c2.Error)
+	}
+	if !errors.As(c3.Error, &re) {
+		t.Errorf("expected *JSONRPCError, got %v", c3.Error)
+	}
+	if notified != 2 {
+		t.Errorf("expected 2 notifications, got %d", notified)
+	}
+}
+
+func TestJSONRPCClient_HttpError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gateway" {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("Bad Gateway"))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"Server error","data":"db"}}`))
+	}))
+	defer ts.Close()
+
+	// 非 2xx 响应包含错误对象
+	err := NewJSONRPCClient(ts.URL, nil).Call("add", []int{1, 2}, nil)
+
+	var re *JSONRPCError
+	if !errors.As(err, &re) || re.Code != -32000 || re.Message != "Server error" || string(re.Data) != `"db"` {
+		t.Errorf("Unexpected error. (%v)", err)
+	}
+
+	// 不包含错误对象时返回 *HttpError
+	err = NewJSONRPCClient(ts.URL+"/gateway", nil).Call("add", []int{1, 2}, nil)
+
+	var he *HttpError
+	if !errors.As(err, &he) || he.StatusCode != http.StatusBadGateway {
+		t.Errorf("Unexpected error. (%v)", err)
+	}
+}
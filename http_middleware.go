@@ -0,0 +1,209 @@
+package goutils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// HttpRoundTripFunc 发送单次 HTTP 请求。(每次重试、重定向均会调用)
+type HttpRoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// HttpMiddleware 请求/响应中间件。可修改请求、检查响应, 或直接返回响应而不调用 next。(例如: Mock)
+type HttpMiddleware func(next HttpRoundTripFunc) HttpRoundTripFunc
+
+// HttpClientOptionWithMiddleware 添加中间件。先添加的中间件位于外层, 最先处理请求、最后处理响应。
+func HttpClientOptionWithMiddleware(middlewares ...HttpMiddleware) HttpClientOption {
+	return func(c *HttpClient) {
+		c.Middlewares = append(c.Middlewares, middlewares...)
+	}
+}
+
+// HttpClientOptionWithBeforeRequest 添加请求发送前回调。返回 error 时中止请求。
+func HttpClientOptionWithBeforeRequest(fn func(req *http.Request) error) HttpClientOption {
+	return HttpClientOptionWithMiddleware(func(next HttpRoundTripFunc) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if err := fn(req); err != nil {
+				return nil, err
+			}
+
+			return next(req)
+		}
+	})
+}
+
+// HttpClientOptionWithAfterResponse 添加收到响应后回调。返回 error 时关闭响应并返回该错误。
+func HttpClientOptionWithAfterResponse(fn func(req *http.Request, resp *http.Response) error) HttpClientOption {
+	return HttpClientOptionWithMiddleware(func(next HttpRoundTripFunc) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := next(req)
+			if err != nil {
+				return nil, err
+			}
+
+			if err = fn(req, resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+
+			return resp, nil
+		}
+	})
+}
+
+// middlewareTransport 将中间件链包装为 http.RoundTripper。
+type middlewareTransport struct {
+	roundTrip HttpRoundTripFunc
+}
+
+func (t *middlewareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.roundTrip(req)
+}
+
+// roundTripper 按限流设置及中间件链包装 Transport。
+func (h *HttpClient) roundTripper(tr http.RoundTripper) http.RoundTripper {
+	// 限流位于中间件链最内层, 每次重试、重定向均受限制
+	if h.rateLimiter != nil {
+		tr = h.rateLimiter.wrap(tr)
+	}
+
+	if len(h.Middlewares) == 0 {
+		return tr
+	}
+
+	fn := HttpRoundTripFunc(tr.RoundTrip)
+
+	for i := len(h.Middlewares) - 1; i >= 0; i-- {
+		fn = h.Middlewares[i](fn)
+	}
+
+	return &middlewareTransport{roundTrip: fn}
+}
+
+type requestIDContextKey struct{}
+
+// WithRequestID 在 ctx 中保存请求 ID, 由 HttpMiddlewareRequestID 中间件传递至请求头。
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext 读取 ctx 中保存的请求 ID。
+func RequestIDFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(requestIDContextKey{}).(string); ok {
+		return v
+	}
+
+	return ""
+}
+
+// HttpMiddlewareRequestID 设置请求 ID 请求头 (默认值: X-Request-ID)。
+// 优先使用已设置的请求头, 其次为 ctx 中保存的请求 ID, 均不存在时随机生成。
+func HttpMiddlewareRequestID(header string) HttpMiddleware {
+	if header == "" {
+		header = "X-Request-ID"
+	}
+
+	return func(next HttpRoundTripFunc) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				id := RequestIDFromContext(req.Context())
+
+				if id == "" {
+					b := make([]byte, 16)
+					_, _ = rand.Read(b)
+					id = hex.EncodeToString(b)
+				}
+
+				req.Header.Set(header, id)
+			}
+
+			return next(req)
+		}
+	}
+}
+
+// 默认脱敏的请求/响应头
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token"}
+
+// redactHeader 复制请求头并隐藏敏感信息。
+func redactHeader(header http.Header, names []string) http.Header {
+	header = header.Clone()
+
+	for _, name := range names {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			header.Set(name, "******")
+		}
+	}
+
+	return header
+}
+
+// HttpMiddlewareDump 输出请求及响应内容至 w (调试用途)。敏感请求头将被隐藏, 可通过 redact 追加需要隐藏的请求头。
+// body 为 true 时同时输出请求体及响应体 (请求体将被完整读入内存)。并发请求的内容按请求/响应整段写入 w, 不会交错。
+func HttpMiddlewareDump(w io.Writer, body bool, redact ...string) HttpMiddleware {
+	names := append(append([]string{}, sensitiveHeaders...), redact...)
+
+	var mu sync.Mutex
+
+	write := func(b []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Write(append(b, '\n'))
+	}
+
+	return func(next HttpRoundTripFunc) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if b, err := dumpRequest(req, body, names); err == nil {
+				write(b)
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				return nil, err
+			}
+
+			if b, err := dumpResponse(resp, body, names); err == nil {
+				write(b)
+			}
+
+			return resp, nil
+		}
+	}
+}
+
+func dumpRequest(req *http.Request, body bool, redact []string) ([]byte, error) {
+	r2 := req.Clone(req.Context())
+	r2.Header = redactHeader(req.Header, redact)
+
+	if body && req.Body != nil && req.Body != http.NoBody {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		r2.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	return httputil.DumpRequestOut(r2, body)
+}
+
+func dumpResponse(resp *http.Response, body bool, redact []string) ([]byte, error) {
+	r2 := *resp
+	r2.Header = redactHeader(resp.Header, redact)
+
+	b, err := httputil.DumpResponse(&r2, body)
+
+	// DumpResponse 读取响应体后会替换为内存副本
+	resp.Body = r2.Body
+
+	return bytes.TrimRight(b, "\r\n"), err
+}
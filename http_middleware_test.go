@@ -0,0 +1,115 @@
+package goutils
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHttpClient_Middleware(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace") + "|" + r.Header.Get("X-Request-ID")))
+	}))
+	defer ts.Close()
+
+	var order []string
+
+	trace := func(name string) HttpMiddleware {
+		return func(next HttpRoundTripFunc) HttpRoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+":before")
+				req.Header.Set("X-Trace", req.Header.Get("X-Trace")+name)
+				resp, err := next(req)
+				order = append(order, name+":after")
+				return resp, err
+			}
+		}
+	}
+
+	var dump bytes.Buffer
+
+	client := NewHttpClient(
+		HttpClientOptionWithMiddleware(trace("a"), trace("b"), HttpMiddlewareRequestID("")),
+		HttpClientOptionWithMiddleware(HttpMiddlewareDump(&dump, true)),
+	)
+
+	resp, err := client.GetContext(WithRequestID(context.Background(), "req-1"), ts.URL, &HttpRequest{
+		Headers: map[string]interface{}{"Authorization": "Bearer secret"},
+	})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if v := resp.ToString(); v != "ab|req-1" {
+		t.Errorf("Unexpected response. (%s)", v)
+	}
+
+	if strings.Join(order, ",") != "a:before,b:before,b:after,a:after" {
+		t.Errorf("Unexpected middleware order. (%v)", order)
+	}
+
+	if strings.Contains(dump.String(), "secret") || !strings.Contains(dump.String(), "ab|req-1") {
+		t.Errorf("Unexpected dump output. (%s)", dump.String())
+	}
+}
+
+func TestHttpClient_MiddlewareDumpConcurrent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var dump bytes.Buffer
+
+	client := NewHttpClient(HttpClientOptionWithMiddleware(HttpMiddlewareDump(&dump, true)))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := client.Get(ts.URL, nil); err != nil {
+				t.Errorf("Request errors. (%v)", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := strings.Count(dump.String(), "GET / HTTP/1.1"); n != 8 {
+		t.Errorf("Unexpected dumped requests. (%d)", n)
+	}
+	if n := strings.Count(dump.String(), "HTTP/1.1 200 OK"); n != 8 {
+		t.Errorf("Unexpected dumped responses. (%d)", n)
+	}
+}
+
+func TestHttpClient_MiddlewareMock(t *testing.T) {
+	client := NewHttpClient(HttpClientOptionWithMiddleware(func(next HttpRoundTripFunc) HttpRoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/plain"}},
+				Body:       ioutil.NopCloser(strings.NewReader("mocked")),
+				Request:    req,
+			}, nil
+		}
+	}))
+
+	resp, err := client.Get("http://example.invalid/", nil)
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if resp.ToString() != "mocked" {
+		t.Errorf("Unexpected response. (%s)", resp.ToString())
+	}
+}
@@ -0,0 +1,171 @@
+package goutils
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HttpFormFile multipart/form-data 上传文件。
+type HttpFormFile struct {
+	// 表单字段名称
+	FieldName string
+	// 本地文件路径
+	Path string
+	// 上传文件名 (默认值: Path 文件名)
+	FileName string
+	// 文件内容类型 (默认按扩展名推断, 无法推断时为 application/octet-stream)
+	ContentType string
+}
+
+func (f *HttpFormFile) fileName() string {
+	if f.FileName != "" {
+		return f.FileName
+	}
+
+	return filepath.Base(f.Path)
+}
+
+func (f *HttpFormFile) contentType() string {
+	if f.ContentType != "" {
+		return f.ContentType
+	}
+
+	if v := mime.TypeByExtension(filepath.Ext(f.Path)); v != "" {
+		return v
+	}
+
+	return "application/octet-stream"
+}
+
+func (f *HttpFormFile) header() textproto.MIMEHeader {
+	quoteEscaper := strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(f.FieldName), quoteEscaper.Replace(f.fileName())))
+	h.Set("Content-Type", f.contentType())
+
+	return h
+}
+
+// multipartFields 展开 FormParams 表单字段。
+func multipartFields(r *HttpRequest) [][2]string {
+	var fields [][2]string
+
+	for k, v := range r.FormParams {
+		if vv, ok := v.(string); ok {
+			fields = append(fields, [2]string{k, vv})
+		}
+		if vv, ok := v.([]string); ok {
+			for _, vvv := range vv {
+				fields = append(fields, [2]string{k, vvv})
+			}
+		}
+	}
+
+	return fields
+}
+
+// countWriter 统计写入字节数。
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+
+	return len(p), nil
+}
+
+// setMultipartBody 设置 multipart/form-data 请求体。文件内容通过 io.Pipe 流式写入, 不在内存中缓存。
+func (h *HttpClient) setMultipartBody(req *http.Request, r *HttpRequest) error {
+	// 其它请求体会替换 multipart 请求体, 导致写入协程永久阻塞
+	if r.Text != "" || r.JSON != nil || r.XML != nil {
+		return errors.New("Files cannot be combined with Text, JSON or XML.")
+	}
+
+	fields := multipartFields(r)
+	sizes := make([]int64, len(r.Files))
+
+	var totalSize int64
+
+	for i, f := range r.Files {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return errors.New(fmt.Sprintf("The upload file cannot be a directory. (%s)", f.Path))
+		}
+
+		sizes[i] = info.Size()
+		totalSize += info.Size()
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	// 预先计算请求体长度, 避免使用 chunked 传输编码 (部分上传接口不支持)
+	counter := &countWriter{}
+	cw := multipart.NewWriter(counter)
+	_ = cw.SetBoundary(writer.Boundary())
+
+	for _, field := range fields {
+		_ = cw.WriteField(field[0], field[1])
+	}
+	for i, f := range r.Files {
+		_, _ = cw.CreatePart(f.header())
+		counter.n += sizes[i]
+	}
+	_ = cw.Close()
+
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.ContentLength = counter.n
+	req.Body = pr
+
+	progress, done := h.progressWriter(r, uint64(totalSize))
+
+	go func() {
+		defer done()
+
+		pw.CloseWithError(writeMultipart(writer, fields, r.Files, progress))
+	}()
+
+	return nil
+}
+
+func writeMultipart(writer *multipart.Writer, fields [][2]string, files []*HttpFormFile, progress io.Writer) error {
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		part, err := writer.CreatePart(f.header())
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(part, io.TeeReader(file, progress))
+		file.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
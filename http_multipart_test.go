@@ -0,0 +1,66 @@
+package goutils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestHttpClient_PostFiles(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength <= 0 {
+			t.Errorf("Content-Length is missing.")
+		}
+
+		if err := r.ParseMultipartForm(1024 * 1024); err != nil {
+			t.Errorf("Multipart parse error. (%v)", err)
+			return
+		}
+
+		f, fh, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("Form file error. (%v)", err)
+			return
+		}
+		defer f.Close()
+
+		b, _ := ioutil.ReadAll(f)
+
+		w.Write([]byte(r.FormValue("foo") + "|" + fh.Filename + "|" + fh.Header.Get("Content-Type") + "|" + string(b)))
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "upload.txt")
+	ioutil.WriteFile(filename, []byte("hello"), 0644)
+
+	client := NewHttpClient()
+	resp, err := client.Post(ts.URL, &HttpRequest{
+		FormParams: map[string]interface{}{"foo": "bar"},
+		Files: []*HttpFormFile{
+			{FieldName: "file", Path: filename, ContentType: "text/plain"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if v := resp.ToString(); v != "bar|upload.txt|text/plain|hello" {
+		t.Errorf("Unexpected response. (%s)", v)
+	}
+}
+
+func TestHttpClient_PostFilesWithBody(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "upload.txt")
+	ioutil.WriteFile(filename, []byte("hello"), 0644)
+
+	client := NewHttpClient()
+	_, err := client.Post("http://127.0.0.1:1", &HttpRequest{
+		JSON:  map[string]string{"foo": "bar"},
+		Files: []*HttpFormFile{{FieldName: "file", Path: filename}},
+	})
+	if err == nil || err.Error() != "Files cannot be combined with Text, JSON or XML." {
+		t.Errorf("Unexpected error. (%v)", err)
+	}
+}
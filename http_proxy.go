@@ -0,0 +1,198 @@
+package goutils
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// HttpProxyRule 代理规则。(类似 PAC 规则, 按添加顺序匹配, 首个匹配的规则生效)
+type HttpProxyRule struct {
+	// 匹配的主机列表 (域名及其子域名、IP、CIDR, "*" 或为空表示全部)
+	Hosts []string
+	// 代理链 (按顺序串联, 支持 http/https/socks5 协议, 为空表示直连)
+	Proxies []string
+}
+
+// HttpClientOptionWithProxyRules 按目标主机选择代理。
+func HttpClientOptionWithProxyRules(rules ...HttpProxyRule) HttpClientOption {
+	return func(c *HttpClient) {
+		c.ProxyRules = append(c.ProxyRules, rules...)
+	}
+}
+
+// HttpClientOptionWithNoProxy 设置不使用代理的主机列表。(格式同 NO_PROXY 环境变量, 优先于代理规则及 HttpRequest.Proxy)
+func HttpClientOptionWithNoProxy(hosts ...string) HttpClientOption {
+	return func(c *HttpClient) {
+		c.NoProxy = append(c.NoProxy, hosts...)
+	}
+}
+
+// matchHost 检查主机是否匹配 patterns？
+func matchHost(host string, patterns []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+		switch {
+		case pattern == "":
+			continue
+		case pattern == "*":
+			return true
+		case strings.Contains(pattern, "/"):
+			if _, ipnet, err := net.ParseCIDR(pattern); err == nil && ip != nil && ipnet.Contains(ip) {
+				return true
+			}
+		default:
+			pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "*"), ".")
+
+			if host == pattern || strings.HasSuffix(host, "."+pattern) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// splitProxies 拆分以逗号分隔的代理链。
+func splitProxies(s string) []string {
+	var proxies []string
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			proxies = append(proxies, v)
+		}
+	}
+
+	return proxies
+}
+
+// proxyChain 选择请求使用的代理链。返回 nil 表示使用 Transport 自身的代理设置, 空切片表示直连。
+func (h *HttpClient) proxyChain(r *HttpRequest, uri string) []string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil
+	}
+
+	host := u.Hostname()
+
+	if matchHost(host, h.NoProxy) {
+		return []string{}
+	}
+
+	if r != nil && r.Proxy != "" {
+		return splitProxies(r.Proxy)
+	}
+
+	for _, rule := range h.ProxyRules {
+		if len(rule.Hosts) == 0 || matchHost(host, rule.Hosts) {
+			if rule.Proxies == nil {
+				return []string{}
+			}
+
+			return rule.Proxies
+		}
+	}
+
+	return nil
+}
+
+// 每个 HttpClient 缓存的代理 Transport 数量上限
+const maxProxyTransports = 16
+
+// proxyTransportCache 按代理链缓存的 Transport。(LRU 淘汰, 淘汰时关闭空闲连接, 避免轮换代理时连接池无限增长)
+type proxyTransportCache struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type proxyTransportItem struct {
+	key string
+	tr  *http.Transport
+}
+
+func (c *proxyTransportCache) get(key string) (*http.Transport, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*proxyTransportItem).tr, true
+	}
+
+	return nil, false
+}
+
+// add 缓存 Transport, 已存在时返回缓存的 Transport。
+func (c *proxyTransportCache) add(key string, tr *http.Transport) *http.Transport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items == nil {
+		c.ll = list.New()
+		c.items = make(map[string]*list.Element)
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*proxyTransportItem).tr
+	}
+
+	c.items[key] = c.ll.PushFront(&proxyTransportItem{key: key, tr: tr})
+
+	for c.ll.Len() > maxProxyTransports {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+
+		item := e.Value.(*proxyTransportItem)
+		delete(c.items, item.key)
+		// 正在使用的连接不受影响, 请求完成后由 IdleConnTimeout 关闭
+		item.tr.CloseIdleConnections()
+	}
+
+	return tr
+}
+
+// proxyTransport 返回使用指定代理链的 Transport。(基于 base 复制, 按代理链缓存以复用连接, 不修改共享的 Transport)
+func (h *HttpClient) proxyTransport(base *http.Transport, chain []string) (*http.Transport, error) {
+	key := fmt.Sprintf("%p|%s", base, strings.Join(chain, ","))
+
+	if tr, ok := h.proxyTransports.get(key); ok {
+		return tr, nil
+	}
+
+	tr := base.Clone()
+	tr.Proxy = nil
+
+	if len(chain) > 0 {
+		// 最后一级代理由 Transport 处理 (支持 HTTP 明文请求的绝对 URI 转发), 之前的各级代理通过拨号器串联
+		last, err := url.Parse(chain[len(chain)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		tr.Proxy = http.ProxyURL(last)
+
+		if len(chain) > 1 {
+			dialer, err := NewProxyChainDialer(chain[:len(chain)-1]...)
+			if err != nil {
+				return nil, err
+			}
+
+			tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialContext(ctx, dialer, network, addr)
+			}
+		}
+	}
+
+	return h.proxyTransports.add(key, tr), nil
+}
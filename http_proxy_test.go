@@ -0,0 +1,150 @@
+package goutils
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestProxyServer 创建支持 CONNECT 隧道及绝对 URI 转发的测试代理服务器。
+func newTestProxyServer(name string, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+
+		if r.Method == "CONNECT" {
+			dst, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+			go func() {
+				io.Copy(dst, conn)
+				dst.Close()
+			}()
+			io.Copy(conn, dst)
+			conn.Close()
+			return
+		}
+
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		w.Header().Set("Via", name)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+}
+
+func TestHttpClient_ProxyChain(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var hits1, hits2 int32
+
+	p1 := newTestProxyServer("p1", &hits1)
+	defer p1.Close()
+
+	p2 := newTestProxyServer("p2", &hits2)
+	defer p2.Close()
+
+	client := NewHttpClient()
+
+	resp, err := client.Get(ts.URL, &HttpRequest{Proxy: p1.URL + "," + p2.URL})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if resp.ToString() != "ok" || resp.Header.Get("Via") != "p2" || atomic.LoadInt32(&hits1) != 1 || atomic.LoadInt32(&hits2) != 1 {
+		t.Errorf("The request did not pass through the proxy chain. (p1: %d, p2: %d)", hits1, hits2)
+	}
+
+}
+
+func TestHttpClient_ProxyRules(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var hits int32
+
+	p := newTestProxyServer("p", &hits)
+	defer p.Close()
+
+	client := NewHttpClient(HttpClientOptionWithProxyRules(HttpProxyRule{Hosts: []string{"127.0.0.0/8"}, Proxies: []string{p.URL}}))
+
+	resp, err := client.Get(ts.URL, nil)
+	if err != nil || resp.Header.Get("Via") != "p" {
+		t.Fatalf("The proxy rule was not applied. (%v)", err)
+	}
+
+	client = NewHttpClient(HttpClientOptionWithProxyRules(HttpProxyRule{Proxies: []string{p.URL}}), HttpClientOptionWithNoProxy("127.0.0.1"))
+
+	resp, err = client.Get(ts.URL, nil)
+	if err != nil || resp.Header.Get("Via") != "" {
+		t.Fatalf("The NO_PROXY list was not applied. (%v)", err)
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	patterns := []string{".example.com", "10.0.0.0/8", "localhost"}
+
+	for host, expected := range map[string]bool{
+		"example.com":     true,
+		"api.example.com": true,
+		"badexample.com":  false,
+		"10.1.2.3":        true,
+		"11.1.2.3":        false,
+		"localhost":       true,
+	} {
+		if matchHost(host, patterns) != expected {
+			t.Errorf("Unexpected match result. (%s)", host)
+		}
+	}
+}
+
+func TestHttpClient_ProxyTransportCache(t *testing.T) {
+	client := NewHttpClient()
+	base := newHttpTransport(nil)
+
+	first, _ := client.proxyTransport(base, []string{"http://127.0.0.1:10000"})
+
+	// 轮换代理时缓存数量不超过上限
+	for i := 1; i <= maxProxyTransports*2; i++ {
+		if _, err := client.proxyTransport(base, []string{fmt.Sprintf("http://127.0.0.1:%d", 10000+i)}); err != nil {
+			t.Fatalf("Unexpected error. (%v)", err)
+		}
+	}
+
+	if n := client.proxyTransports.ll.Len(); n != maxProxyTransports {
+		t.Errorf("Unexpected cached transports. (%d)", n)
+	}
+
+	if tr, _ := client.proxyTransport(base, []string{"http://127.0.0.1:10000"}); tr == first {
+		t.Errorf("The least recently used transport should be evicted.")
+	}
+
+	last := fmt.Sprintf("http://127.0.0.1:%d", 10000+maxProxyTransports*2)
+	a, _ := client.proxyTransport(base, []string{last})
+	b, _ := client.proxyTransport(base, []string{last})
+	if a != b {
+		t.Errorf("The transport should be reused for the same proxy.")
+	}
+}
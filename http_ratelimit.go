@@ -0,0 +1,293 @@
+package goutils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HttpRateLimit 请求频率限制参数。(速率单位: 次/秒, 小于等于 0 表示不限制)
+type HttpRateLimit struct {
+	// 每个主机的请求速率
+	PerHostRate float64
+	// 每个主机允许的突发请求数 (默认值: 1)
+	PerHostBurst int
+	// 全局请求速率
+	GlobalRate float64
+	// 全局允许的突发请求数 (默认值: 1)
+	GlobalBurst int
+	// 每个主机的最大并发请求数
+	MaxConcurrencyPerHost int
+	// 全局最大并发请求数
+	MaxConcurrency int
+}
+
+// HttpClientOptionWithRateLimit 启用令牌桶限流。等待期间遵循请求的 context 及超时设置。
+func HttpClientOptionWithRateLimit(l HttpRateLimit) HttpClientOption {
+	return func(c *HttpClient) {
+		c.rateLimiter = newHttpRateLimiter(l)
+	}
+}
+
+// tokenBucket 令牌桶。
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve 预占一个令牌, 返回需等待的时长。
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel 归还预占的令牌。
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// wait 等待获取令牌, ctx 取消时归还令牌并返回 error。
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if err := sleepContext(ctx, b.reserve()); err != nil {
+		b.cancel()
+		return err
+	}
+
+	return nil
+}
+
+// semaphore 并发数限制。
+type semaphore chan struct{}
+
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release() {
+	<-s
+}
+
+// 空闲主机的限流状态保留时间 (令牌桶补满所需时间更长时以其为准)
+const httpRateLimiterIdleTimeout = time.Minute
+
+type httpRateLimiter struct {
+	config HttpRateLimit
+	global *tokenBucket
+	sem    semaphore
+
+	mu        sync.Mutex
+	hosts     map[string]*hostRateLimiter
+	lastSweep time.Time
+}
+
+// hostRateLimiter 单个主机的限流状态。
+type hostRateLimiter struct {
+	bucket *tokenBucket
+	sem    semaphore
+	// 正在使用的请求数
+	refs     int
+	lastUsed time.Time
+}
+
+func newHttpRateLimiter(l HttpRateLimit) *httpRateLimiter {
+	limiter := &httpRateLimiter{
+		config:    l,
+		hosts:     make(map[string]*hostRateLimiter),
+		lastSweep: time.Now(),
+	}
+
+	if l.GlobalRate > 0 {
+		limiter.global = newTokenBucket(l.GlobalRate, l.GlobalBurst)
+	}
+
+	if l.MaxConcurrency > 0 {
+		limiter.sem = make(semaphore, l.MaxConcurrency)
+	}
+
+	return limiter
+}
+
+// idleTimeout 返回空闲主机的保留时间。令牌桶补满前移除会放宽限流, 因此不小于补满所需时间。
+func (l *httpRateLimiter) idleTimeout() time.Duration {
+	idle := httpRateLimiterIdleTimeout
+
+	if l.config.PerHostRate > 0 {
+		burst := l.config.PerHostBurst
+		if burst < 1 {
+			burst = 1
+		}
+
+		if refill := time.Duration(float64(burst) / l.config.PerHostRate * float64(time.Second)); refill > idle {
+			idle = refill
+		}
+	}
+
+	return idle
+}
+
+// host 返回主机的限流状态并增加引用计数, 使用完毕后需调用 done。
+func (l *httpRateLimiter) host(host string) *hostRateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	idle := l.idleTimeout()
+
+	// 定期移除空闲主机, 避免访问大量主机时无限增长
+	if now.Sub(l.lastSweep) >= idle {
+		for k, v := range l.hosts {
+			if v.refs == 0 && now.Sub(v.lastUsed) >= idle {
+				delete(l.hosts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostRateLimiter{}
+
+		if l.config.PerHostRate > 0 {
+			h.bucket = newTokenBucket(l.config.PerHostRate, l.config.PerHostBurst)
+		}
+
+		if l.config.MaxConcurrencyPerHost > 0 {
+			h.sem = make(semaphore, l.config.MaxConcurrencyPerHost)
+		}
+
+		l.hosts[host] = h
+	}
+
+	h.refs++
+	h.lastUsed = now
+
+	return h
+}
+
+func (l *httpRateLimiter) done(h *hostRateLimiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h.refs--
+	h.lastUsed = time.Now()
+}
+
+// acquire 等待并发及频率限制, 返回释放并发占用的回调。
+// 先获取主机的并发占用及令牌, 最后获取全局并发占用, 避免等待繁忙主机的请求占用全局并发数。
+func (l *httpRateLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	h := l.host(host)
+
+	var sems []semaphore
+
+	release := func() {
+		for _, sem := range sems {
+			sem.release()
+		}
+
+		l.done(h)
+	}
+
+	if h.sem != nil {
+		if err := h.sem.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+
+		sems = append(sems, h.sem)
+	}
+
+	for _, b := range []*tokenBucket{h.bucket, l.global} {
+		if b == nil {
+			continue
+		}
+
+		if err := b.wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+
+	if l.sem != nil {
+		if err := l.sem.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+
+		sems = append(sems, l.sem)
+	}
+
+	return release, nil
+}
+
+// wrap 包装 http.RoundTripper, 并发占用在响应体关闭后释放。
+func (l *httpRateLimiter) wrap(next http.RoundTripper) http.RoundTripper {
+	return &middlewareTransport{roundTrip: func(req *http.Request) (*http.Response, error) {
+		release, err := l.acquire(req.Context(), req.URL.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+
+		return resp, nil
+	}}
+}
+
+// releaseOnClose 关闭时执行一次 release 回调。
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+
+	return err
+}
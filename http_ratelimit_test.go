@@ -0,0 +1,122 @@
+package goutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_RateLimit(t *testing.T) {
+	var current, peak int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}))
+	defer ts.Close()
+
+	client := NewHttpClient(HttpClientOptionWithRateLimit(HttpRateLimit{PerHostRate: 20, MaxConcurrencyPerHost: 2}))
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Get(ts.URL, nil); err != nil {
+				t.Errorf("Request errors. (%v)", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if d := time.Since(start); d < time.Millisecond*180 {
+		t.Errorf("Requests were not throttled. (%v)", d)
+	}
+
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("Concurrency cap exceeded. (%d)", p)
+	}
+
+	// 等待令牌期间 context 超时
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	client = NewHttpClient(HttpClientOptionWithRateLimit(HttpRateLimit{GlobalRate: 0.1}))
+	client.Get(ts.URL, nil)
+
+	if _, err := client.GetContext(ctx, ts.URL, nil); err == nil {
+		t.Errorf("Expected a context deadline error.")
+	}
+}
+
+func TestHttpRateLimiter_Acquire(t *testing.T) {
+	l := newHttpRateLimiter(HttpRateLimit{MaxConcurrency: 2, MaxConcurrencyPerHost: 1})
+
+	releaseA, err := l.acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Unexpected error. (%v)", err)
+	}
+
+	// 等待主机 a 的请求不占用全局并发数
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	blocked := make(chan error, 1)
+	go func() {
+		release, err := l.acquire(ctx, "a")
+		if err == nil {
+			release()
+		}
+		blocked <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	releaseB, err := l.acquire(ctx, "b")
+	if err != nil {
+		t.Fatalf("The request to another host should not be starved. (%v)", err)
+	}
+
+	releaseB()
+	releaseA()
+
+	if err = <-blocked; err != nil {
+		t.Errorf("Unexpected error. (%v)", err)
+	}
+}
+
+func TestHttpRateLimiter_Evict(t *testing.T) {
+	l := newHttpRateLimiter(HttpRateLimit{PerHostRate: 10, MaxConcurrencyPerHost: 1})
+
+	release, _ := l.acquire(context.Background(), "a")
+	release()
+
+	// 空闲超过保留时间的主机被移除
+	l.mu.Lock()
+	l.hosts["a"].lastUsed = time.Now().Add(-2 * httpRateLimiterIdleTimeout)
+	l.lastSweep = time.Now().Add(-2 * httpRateLimiterIdleTimeout)
+	l.mu.Unlock()
+
+	release, _ = l.acquire(context.Background(), "b")
+	defer release()
+
+	if _, ok := l.hosts["a"]; ok || len(l.hosts) != 1 {
+		t.Errorf("Idle hosts should be evicted. (%d)", len(l.hosts))
+	}
+}
@@ -0,0 +1,424 @@
+package goutils
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RestClient 声明式 REST 客户端。
+//
+// 通过 Bind 为结构体中带 rest 标签的函数字段生成实现, 例如:
+//
+//	type UserAPI struct {
+//		GetUser    func(ctx context.Context, in *GetUserInput) (*User, error) `rest:"GET /users/{id}"`
+//		DeleteUser func(ctx context.Context, in *GetUserInput) error         `rest:"DELETE /users/{id}"`
+//	}
+//
+// 函数参数可选 context.Context 及一个请求结构体 (或其指针), 返回值为 error 或 (T, error)。
+// 请求结构体字段通过以下标签映射到请求:
+//
+//	path:"id"              路径参数 {id}
+//	query:"page,omitempty" 查询参数 (切片生成多个同名参数)
+//	header:"X-Token"       请求头
+//	form:"name"            表单参数
+//	file:"-"               上传文件 (*HttpFormFile 或 []*HttpFormFile)
+//	body:"json"            请求体 (json/xml/text, 默认值: json)
+//
+// 返回值 T 为 *HttpResponse、string 或 []byte 时返回原始响应, 否则按响应 Content-Type 解码 JSON/XML。
+type RestClient struct {
+	// 接口根地址 (如: https://api.example.com/v1)
+	BaseURL string
+	// HTTP 客户端 (默认值: NewHttpClient())
+	Client *HttpClient
+	// 公共请求头
+	Headers map[string]string
+	// 超时 (默认值: 60s)
+	Timeout time.Duration
+	// 非 2xx 响应的错误转换 (如解析接口返回的错误码)。返回 nil 时使用原始 *HttpError
+	ErrorDecoder func(e *HttpError) error
+}
+
+// NewRestClient 创建声明式 REST 客户端。
+func NewRestClient(baseURL string, client *HttpClient) *RestClient {
+	return &RestClient{BaseURL: baseURL, Client: client}
+}
+
+var (
+	contextType      = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType        = reflect.TypeOf((*error)(nil)).Elem()
+	httpResponseType = reflect.TypeOf((*HttpResponse)(nil))
+	formFileType     = reflect.TypeOf((*HttpFormFile)(nil))
+	restPathParam    = regexp.MustCompile(`\{([^{}]+)\}`)
+)
+
+// Bind 为 api (结构体指针) 中带 rest 标签的函数字段生成实现。
+func (c *RestClient) Bind(api interface{}) error {
+	v := reflect.ValueOf(api)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("The api must be a pointer to struct.")
+	}
+
+	v = v.Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag, ok := field.Tag.Lookup("rest")
+		if !ok {
+			continue
+		}
+
+		if field.Type.Kind() != reflect.Func || field.PkgPath != "" {
+			return errors.New(fmt.Sprintf("The rest field must be an exported func. (%s)", field.Name))
+		}
+
+		parts := strings.Fields(tag)
+		if len(parts) != 2 {
+			return errors.New(fmt.Sprintf("Invalid rest tag, expected \"METHOD /path\". (%s: %s)", field.Name, tag))
+		}
+
+		e := &restEndpoint{client: c, name: field.Name, method: strings.ToUpper(parts[0]), path: parts[1]}
+		if err := e.parse(field.Type); err != nil {
+			return err
+		}
+
+		v.Field(i).Set(reflect.MakeFunc(field.Type, e.call))
+	}
+
+	return nil
+}
+
+type restEndpoint struct {
+	client *RestClient
+	name   string
+	method string
+	path   string
+
+	ctxIndex int
+	inIndex  int
+	out      reflect.Type
+}
+
+// parse 校验函数签名。
+func (e *restEndpoint) parse(ft reflect.Type) error {
+	e.ctxIndex, e.inIndex = -1, -1
+
+	for i := 0; i < ft.NumIn(); i++ {
+		in := ft.In(i)
+
+		switch {
+		case i == 0 && in == contextType:
+			e.ctxIndex = i
+		case e.inIndex < 0 && (in.Kind() == reflect.Struct || in.Kind() == reflect.Ptr && in.Elem().Kind() == reflect.Struct):
+			e.inIndex = i
+		default:
+			return errors.New(fmt.Sprintf("Unsupported rest func argument. (%s: %s)", e.name, in))
+		}
+	}
+
+	switch ft.NumOut() {
+	case 1:
+	case 2:
+		e.out = ft.Out(0)
+	default:
+		return errors.New(fmt.Sprintf("The rest func must return error or (T, error). (%s)", e.name))
+	}
+
+	if ft.Out(ft.NumOut()-1) != errorType {
+		return errors.New(fmt.Sprintf("The last return value of the rest func must be error. (%s)", e.name))
+	}
+
+	return nil
+}
+
+func (e *restEndpoint) call(args []reflect.Value) []reflect.Value {
+	ctx := context.Background()
+	if e.ctxIndex >= 0 && !args[e.ctxIndex].IsNil() {
+		ctx = args[e.ctxIndex].Interface().(context.Context)
+	}
+
+	var in reflect.Value
+	if e.inIndex >= 0 {
+		in = args[e.inIndex]
+	}
+
+	result, err := e.do(ctx, in)
+
+	if e.out == nil {
+		return []reflect.Value{errorValue(err)}
+	}
+
+	if err != nil || !result.IsValid() {
+		result = reflect.Zero(e.out)
+	}
+
+	return []reflect.Value{result, errorValue(err)}
+}
+
+func errorValue(err error) reflect.Value {
+	if err == nil {
+		return reflect.Zero(errorType)
+	}
+
+	return reflect.ValueOf(&err).Elem()
+}
+
+func (e *restEndpoint) do(ctx context.Context, in reflect.Value) (reflect.Value, error) {
+	r := &HttpRequest{Timeout: e.client.Timeout, Headers: map[string]interface{}{}}
+
+	for k, v := range e.client.Headers {
+		r.Headers[k] = v
+	}
+
+	params := map[string]string{}
+
+	if in.IsValid() {
+		if err := buildRestRequest(in, r, params); err != nil {
+			return reflect.Value{}, errors.Wrap(err, e.name)
+		}
+	}
+
+	var missing []string
+
+	path := restPathParam.ReplaceAllStringFunc(e.path, func(s string) string {
+		name := s[1 : len(s)-1]
+
+		v, ok := params[name]
+		if !ok || v == "" {
+			missing = append(missing, name)
+		}
+
+		return url.PathEscape(v)
+	})
+
+	if len(missing) > 0 {
+		return reflect.Value{}, errors.New(fmt.Sprintf("Missing path parameters. (%s: %s)", e.name, strings.Join(missing, ", ")))
+	}
+
+	client := e.client.Client
+	if client == nil {
+		client = NewHttpClient()
+	}
+
+	resp, err := client.RequestContext(ctx, e.method, strings.TrimRight(e.client.BaseURL, "/")+path, r)
+	if err != nil {
+		var he *HttpError
+		if errors.As(err, &he) && e.client.ErrorDecoder != nil {
+			if err2 := e.client.ErrorDecoder(he); err2 != nil {
+				return reflect.Value{}, err2
+			}
+		}
+
+		return reflect.Value{}, err
+	}
+
+	if e.out == nil {
+		return reflect.Value{}, nil
+	}
+
+	return decodeRestResponse(resp, e.out)
+}
+
+// buildRestRequest 按字段标签将请求结构体写入 HttpRequest。
+func buildRestRequest(in reflect.Value, r *HttpRequest, params map[string]string) error {
+	if in.Kind() == reflect.Ptr {
+		if in.IsNil() {
+			return nil
+		}
+		in = in.Elem()
+	}
+
+	t := in.Type()
+	query := map[string]interface{}{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fv := in.Field(i)
+
+		// omitempty 仅跳过当前标签, 不影响同一字段的其它标签
+		if name, omitempty, ok := restTag(field, "path"); ok && !(omitempty && fv.IsZero()) {
+			params[name] = restString(fv)
+		}
+
+		if name, omitempty, ok := restTag(field, "query"); ok && !(omitempty && fv.IsZero()) {
+			query[name] = restValues(fv)
+		}
+
+		if name, omitempty, ok := restTag(field, "header"); ok && !(omitempty && fv.IsZero()) {
+			r.Headers[name] = restValues(fv)
+		}
+
+		if name, omitempty, ok := restTag(field, "form"); ok && !(omitempty && fv.IsZero()) {
+			if r.FormParams == nil {
+				r.FormParams = map[string]interface{}{}
+			}
+			r.FormParams[name] = restValues(fv)
+		}
+
+		if _, ok := field.Tag.Lookup("file"); ok {
+			switch {
+			case fv.Type() == formFileType:
+				if !fv.IsNil() {
+					r.Files = append(r.Files, fv.Interface().(*HttpFormFile))
+				}
+			case fv.Type() == reflect.SliceOf(formFileType):
+				r.Files = append(r.Files, fv.Interface().([]*HttpFormFile)...)
+			default:
+				return errors.New(fmt.Sprintf("The file field must be *HttpFormFile or []*HttpFormFile. (%s)", field.Name))
+			}
+		}
+
+		if format, ok := field.Tag.Lookup("body"); ok && !(fv.Kind() == reflect.Ptr && fv.IsNil()) {
+			switch format {
+			case "", "json":
+				r.JSON = fv.Interface()
+			case "xml":
+				r.XML = fv.Interface()
+			case "text":
+				r.Text = restString(fv)
+			default:
+				return errors.New(fmt.Sprintf("Unsupported body format. (%s: %s)", field.Name, format))
+			}
+		}
+	}
+
+	if len(query) > 0 {
+		r.Query = query
+	}
+
+	return nil
+}
+
+// restTag 解析 name[,omitempty] 形式的标签。
+func restTag(field reflect.StructField, key string) (string, bool, bool) {
+	tag, ok := field.Tag.Lookup(key)
+	if !ok || tag == "-" {
+		return "", false, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	omitempty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+
+	return name, omitempty, true
+}
+
+// restValues 将字段值转换为 string 或 []string (切片)。
+func restValues(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]string, v.Len())
+		for i := range values {
+			values[i] = restScalar(v.Index(i))
+		}
+		return values
+	}
+
+	return restScalar(v)
+}
+
+// restString 将字段值转换为 string。(切片以逗号分隔)
+func restString(v reflect.Value) string {
+	switch values := restValues(v).(type) {
+	case []string:
+		return strings.Join(values, ",")
+	case string:
+		return values
+	}
+
+	return ""
+}
+
+// restScalar 将单个值转换为 string。(指针取值, time.Time 使用 RFC3339 格式)
+func restScalar(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return string(v.Bytes())
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+// decodeRestResponse 将响应解码为 t 类型。
+func decodeRestResponse(resp *HttpResponse, t reflect.Type) (reflect.Value, error) {
+	switch {
+	case t == httpResponseType:
+		return reflect.ValueOf(resp), nil
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf(string(resp.Body)).Convert(t), nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return reflect.ValueOf(resp.Body).Convert(t), nil
+	}
+
+	ptr := t.Kind() == reflect.Ptr
+
+	var v reflect.Value
+	if ptr {
+		v = reflect.New(t.Elem())
+	} else {
+		v = reflect.New(t)
+	}
+
+	if len(resp.Body) == 0 || resp.StatusCode == http.StatusNoContent {
+		if ptr {
+			return reflect.Zero(t), nil
+		}
+		return v.Elem(), nil
+	}
+
+	var err error
+	if strings.Contains(resp.ContentType, "xml") {
+		err = xml.Unmarshal(resp.Body, v.Interface())
+	} else {
+		err = json.Unmarshal(resp.Body, v.Interface())
+	}
+
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	if ptr {
+		return v, nil
+	}
+
+	return v.Elem(), nil
+}
@@ -0,0 +1,182 @@
+package goutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type restTestUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type restTestGetUser struct {
+	ID    string   `path:"id"`
+	Tags  []string `query:"tag,omitempty"`
+	Page  int      `query:"page,omitempty"`
+	Token string   `header:"X-Token"`
+}
+
+type restTestCreateUser struct {
+	Org  string        `path:"org"`
+	User *restTestUser `body:"json"`
+}
+
+type restTestAPI struct {
+	GetUser    func(ctx context.Context, in *restTestGetUser) (*restTestUser, error) `rest:"GET /users/{id}"`
+	CreateUser func(in restTestCreateUser) (restTestUser, error)                     `rest:"POST /orgs/{org}/users"`
+	DeleteUser func(ctx context.Context, in *restTestGetUser) error                  `rest:"DELETE /users/{id}"`
+	Ping       func() (string, error)                                                `rest:"GET /ping"`
+}
+
+type restTestError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *restTestError) Error() string {
+	return e.Message
+}
+
+func TestRestClient_Bind(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/ping":
+			w.Write([]byte("pong"))
+		case r.Method == "GET" && r.URL.Path == "/users/a b":
+			if r.Header.Get("X-Token") != "t1" || r.Header.Get("X-App") != "test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if q := r.URL.Query(); len(q["tag"]) != 2 || q.Get("page") != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"a b","name":"Alice"}`))
+		case r.Method == "POST" && r.URL.Path == "/orgs/dev/users":
+			var u restTestUser
+			b, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(b, &u)
+			u.ID = "new"
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(u)
+		case r.Method == "DELETE":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":404,"message":"user not found"}`))
+		}
+	}))
+	defer ts.Close()
+
+	c := NewRestClient(ts.URL+"/", NewHttpClient())
+	c.Headers = map[string]string{"X-App": "test"}
+	c.ErrorDecoder = func(e *HttpError) error {
+		var re restTestError
+		if json.Unmarshal(e.Body, &re) != nil {
+			return nil
+		}
+		return &re
+	}
+
+	var api restTestAPI
+	if err := c.Bind(&api); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := api.Ping()
+	if err != nil || s != "pong" {
+		t.Errorf("Ping: %q, %v", s, err)
+	}
+
+	u, err := api.GetUser(context.Background(), &restTestGetUser{ID: "a b", Tags: []string{"x", "y"}, Token: "t1"})
+	if err != nil || u.Name != "Alice" {
+		t.Errorf("GetUser: %+v, %v", u, err)
+	}
+
+	created, err := api.CreateUser(restTestCreateUser{Org: "dev", User: &restTestUser{Name: "Bob"}})
+	if err != nil || created.ID != "new" || created.Name != "Bob" {
+		t.Errorf("CreateUser: %+v, %v", created, err)
+	}
+
+	err = api.DeleteUser(context.Background(), &restTestGetUser{ID: "x"})
+	var re *restTestError
+	if !errors.As(err, &re) || re.Code != 404 {
+		t.Errorf("DeleteUser: expected *restTestError, got %v", err)
+	}
+
+	if err = api.DeleteUser(context.Background(), &restTestGetUser{}); err == nil {
+		t.Error("expected error for empty path parameter")
+	}
+}
+
+type restTestUpdateItem struct {
+	ID    *int     `path:"id"`
+	Tags  []string `form:"tag"`
+	Note  *string  `form:"note"`
+	Trace string   `query:"trace,omitempty" header:"X-Trace"`
+}
+
+func TestRestClient_BindValues(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		_, query := r.URL.Query()["trace"]
+		_, header := r.Header["X-Trace"]
+
+		fmt.Fprintf(w, "%s|%s|%s|%v|%v", r.URL.Path, strings.Join(r.PostForm["tag"], ","), r.PostForm.Get("note"), query, header)
+	}))
+	defer ts.Close()
+
+	var api struct {
+		UpdateItem func(in *restTestUpdateItem) (string, error) `rest:"POST /items/{id}"`
+	}
+
+	if err := NewRestClient(ts.URL, nil).Bind(&api); err != nil {
+		t.Fatalf("Bind errors. (%v)", err)
+	}
+
+	// 指针字段取值, 切片按多个值提交, omitempty 仅跳过当前标签
+	id, note := 42, "hello"
+
+	s, err := api.UpdateItem(&restTestUpdateItem{ID: &id, Tags: []string{"a", "b"}, Note: &note})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if s != "/items/42|a,b|hello|false|true" {
+		t.Errorf("Unexpected response. (%s)", s)
+	}
+}
+
+func TestRestClient_BindInvalid(t *testing.T) {
+	c := NewRestClient("http://localhost", nil)
+
+	var api1 struct {
+		Get func(string) error `rest:"GET /"`
+	}
+	if err := c.Bind(&api1); err == nil {
+		t.Error("expected error for unsupported argument")
+	}
+
+	var api2 struct {
+		Get func() string `rest:"GET /"`
+	}
+	if err := c.Bind(&api2); err == nil {
+		t.Error("expected error for missing error return")
+	}
+
+	var api3 struct {
+		Get func() error `rest:"/"`
+	}
+	if err := c.Bind(&api3); err == nil {
+		t.Error("expected error for invalid tag")
+	}
+}
@@ -0,0 +1,220 @@
+package goutils
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HttpRetryPolicy HTTP 请求重试策略。
+type HttpRetryPolicy struct {
+	// 最大尝试次数 (含首次请求, 默认值: 3, 设为 1 时不重试)
+	MaxAttempts int
+	// 首次重试等待时长 (默认值: 500ms)
+	InitialInterval time.Duration
+	// 最大等待时长, 同时限制 Retry-After 响应头 (默认值: 30s)
+	MaxInterval time.Duration
+	// 退避倍数 (默认值: 2)
+	Multiplier float64
+	// 随机抖动比例, 取值范围 [0, 1]。(例如: 0.2 表示在退避时长基础上随机减少最多 20%)
+	Jitter float64
+	// 可重试的响应状态码 (默认值: 429, 502, 503, 504)
+	RetryableStatusCodes []int
+	// 是否遵循 Retry-After 响应头？
+	RespectRetryAfter bool
+	// 是否允许重试 POST/PATCH 等非幂等请求？
+	RetryNonIdempotent bool
+}
+
+// DefaultHttpRetryPolicy 创建默认重试策略。
+func DefaultHttpRetryPolicy() *HttpRetryPolicy {
+	return &HttpRetryPolicy{
+		MaxAttempts:          3,
+		InitialInterval:      time.Millisecond * 500,
+		MaxInterval:          time.Second * 30,
+		Multiplier:           2,
+		Jitter:               0.2,
+		RetryableStatusCodes: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RespectRetryAfter:    true,
+	}
+}
+
+func HttpClientOptionWithRetry(p *HttpRetryPolicy) HttpClientOption {
+	return func(c *HttpClient) {
+		c.Retry = p
+	}
+}
+
+// isIdempotentMethod 检查请求方法是否幂等？
+func isIdempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+// maxAttempts 返回最大尝试次数。(未设置时为 3)
+func (p *HttpRetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 0 {
+		return 1
+	}
+
+	if p.MaxAttempts == 0 {
+		return 3
+	}
+
+	return p.MaxAttempts
+}
+
+func (p *HttpRetryPolicy) attempts(method string) int {
+	if p == nil {
+		return 1
+	}
+
+	if !p.RetryNonIdempotent && !isIdempotentMethod(method) {
+		return 1
+	}
+
+	return p.maxAttempts()
+}
+
+func (p *HttpRetryPolicy) retryableStatus(code int) bool {
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+
+	for _, v := range codes {
+		if v == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff 计算第 n 次重试 (从 1 开始) 前的等待时长。
+func (p *HttpRetryPolicy) backoff(n int, resp *http.Response) time.Duration {
+	interval := p.InitialInterval
+	if interval <= 0 {
+		interval = time.Millisecond * 500
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	maxInterval := p.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = time.Second * 30
+	}
+
+	wait := float64(interval) * math.Pow(multiplier, float64(n-1))
+
+	if wait > float64(maxInterval) {
+		wait = float64(maxInterval)
+	}
+
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		wait -= wait * jitter * rand.Float64()
+	}
+
+	d := time.Duration(wait)
+
+	if p.RespectRetryAfter && resp != nil {
+		// 服务器要求的等待时长不超过 MaxInterval, 避免长时间阻塞
+		if ra := parseRetryAfter(resp.Header.Get("Retry-After")); ra > d {
+			d = ra
+		}
+
+		if d > maxInterval {
+			d = maxInterval
+		}
+	}
+
+	return d
+}
+
+// parseRetryAfter 解析 Retry-After 响应头。(支持秒数及 HTTP 日期格式)
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0
+		}
+
+		return time.Duration(sec) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// sleepContext 等待 d 时长, ctx 取消时提前返回 error。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do 发送请求, 并按重试策略处理网络错误及可重试的响应状态码。
+func (h *HttpClient) do(ctx context.Context, client *http.Client, method, uri string, r *HttpRequest) (*http.Response, error) {
+	attempts := h.Retry.attempts(method)
+
+	for n := 1; ; n++ {
+		req, err := h.newRequest(ctx, method, uri, r)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+
+		if n >= attempts || ctx.Err() != nil {
+			return resp, err
+		}
+
+		if err == nil && !h.Retry.retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		wait := h.Retry.backoff(n, resp)
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
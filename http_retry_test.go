@@ -0,0 +1,91 @@
+package goutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Retry(t *testing.T) {
+	var hits int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	policy := DefaultHttpRetryPolicy()
+	policy.InitialInterval = time.Millisecond * 10
+
+	client := NewHttpClient(HttpClientOptionWithRetry(policy))
+
+	resp, err := client.Get(ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if resp.ToString() != "ok" || atomic.LoadInt32(&hits) != 3 {
+		t.Errorf("Unexpected result. (body: %s, hits: %d)", resp.ToString(), hits)
+	}
+
+	// POST 默认不重试
+	atomic.StoreInt32(&hits, 0)
+
+	if _, err = client.Post(ts.URL, &HttpRequest{Text: "foo"}); err == nil {
+		t.Errorf("Expected an error for the non-retried POST request.")
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("POST should not be retried by default. (hits: %d)", n)
+	}
+
+	// 未设置的字段使用默认值
+	atomic.StoreInt32(&hits, 0)
+
+	client = NewHttpClient(HttpClientOptionWithRetry(&HttpRetryPolicy{InitialInterval: time.Millisecond * 10}))
+
+	if resp, err = client.Get(ts.URL, nil); err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	if n := atomic.LoadInt32(&hits); resp.ToString() != "ok" || n != 3 {
+		t.Errorf("Unexpected result. (body: %s, hits: %d)", resp.ToString(), n)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != time.Second*3 {
+		t.Errorf("Unexpected duration. (%v)", d)
+	}
+
+	if d := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); d < time.Minute*59 {
+		t.Errorf("Unexpected duration. (%v)", d)
+	}
+}
+
+func TestHttpRetryPolicy_Backoff(t *testing.T) {
+	p := &HttpRetryPolicy{InitialInterval: time.Second, MaxInterval: time.Second * 5, RespectRetryAfter: true}
+	resp := &http.Response{Header: http.Header{"Retry-After": {"3600"}}}
+
+	// Retry-After 不超过 MaxInterval
+	if d := p.backoff(1, resp); d != time.Second*5 {
+		t.Errorf("Unexpected duration. (%v)", d)
+	}
+
+	resp.Header.Set("Retry-After", "2")
+	if d := p.backoff(1, resp); d != time.Second*2 {
+		t.Errorf("Unexpected duration. (%v)", d)
+	}
+
+	// 未设置 MaxInterval 时使用默认值 30s
+	p.MaxInterval = 0
+	if d := p.backoff(10, nil); d != time.Second*30 {
+		t.Errorf("Unexpected duration. (%v)", d)
+	}
+}
@@ -0,0 +1,204 @@
+package goutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NDJSONDecoder 逐行解码 NDJSON (换行分隔的 JSON) 数据流。
+type NDJSONDecoder struct {
+	r *bufio.Reader
+}
+
+func NewNDJSONDecoder(r io.Reader) *NDJSONDecoder {
+	return &NDJSONDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode 解码下一行 JSON 数据至 v。(忽略空行, 数据流结束时返回 io.EOF)
+func (d *NDJSONDecoder) Decode(v interface{}) error {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+
+		if len(line) > 0 {
+			return json.Unmarshal(line, v)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SSEEvent Server-Sent Events 事件。
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// SSEReader 解析 Server-Sent Events (text/event-stream) 数据流。
+type SSEReader struct {
+	r *bufio.Reader
+	// 最近一次收到的事件 ID
+	LastEventID string
+	// 服务器指定的重连间隔
+	Retry time.Duration
+}
+
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{r: bufio.NewReader(r)}
+}
+
+// Next 读取下一个事件。(数据流结束时返回 io.EOF, 未完成的事件将被丢弃)
+func (s *SSEReader) Next() (*SSEEvent, error) {
+	var (
+		data    []string
+		event   string
+		retry   time.Duration
+		hasData bool
+	)
+
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		// 空行表示事件结束
+		if line == "" {
+			if !hasData {
+				event = ""
+				retry = 0
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			return &SSEEvent{
+				ID:    s.LastEventID,
+				Event: event,
+				Data:  strings.Join(data, "\n"),
+				Retry: retry,
+			}, nil
+		}
+
+		if err == io.EOF {
+			return nil, err
+		}
+
+		// 注释行
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+
+		if i := strings.Index(line, ":"); i >= 0 {
+			field = line[:i]
+			value = strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.LastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				retry = time.Duration(ms) * time.Millisecond
+				s.Retry = retry
+			}
+		}
+	}
+}
+
+// SubscribeSSE 订阅 Server-Sent Events 事件流。
+// 连接断开后按服务器指定的 retry 间隔 (默认值: 3s) 自动重连并携带 Last-Event-ID 请求头，
+// 直至 ctx 取消、服务器返回 204 状态或 handler 返回 error。
+func (h *HttpClient) SubscribeSSE(ctx context.Context, uri string, r *HttpRequest, handler func(*SSEEvent) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if r == nil {
+		r = &HttpRequest{}
+	}
+
+	lastEventID := ""
+	retry := time.Second * 3
+
+	for {
+		headers := map[string]string{
+			"Accept":        "text/event-stream",
+			"Cache-Control": "no-cache",
+		}
+
+		if lastEventID != "" {
+			headers["Last-Event-ID"] = lastEventID
+		}
+
+		rr := withRequestHeaders(r, headers)
+		rr.Stream = true
+		rr.AllowNon200Response = false
+
+		resp, err := h.GetContext(ctx, uri, rr)
+
+		// 服务器返回错误状态时不再重连
+		var httpErr *HttpError
+		if errors.As(err, &httpErr) {
+			return err
+		}
+
+		if err == nil {
+			if resp.StatusCode == http.StatusNoContent {
+				resp.Reader.Close()
+				return nil
+			}
+
+			reader := NewSSEReader(resp.Reader)
+			reader.LastEventID = lastEventID
+
+			for {
+				ev, err := reader.Next()
+				if err != nil {
+					break
+				}
+
+				if err = handler(ev); err != nil {
+					resp.Reader.Close()
+					return err
+				}
+			}
+
+			resp.Reader.Close()
+
+			lastEventID = reader.LastEventID
+
+			if reader.Retry > 0 {
+				retry = reader.Retry
+			}
+		}
+
+		if err := sleepContext(ctx, retry); err != nil {
+			return err
+		}
+	}
+}
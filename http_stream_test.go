@@ -0,0 +1,94 @@
+package goutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNDJSONDecoder(t *testing.T) {
+	dec := NewNDJSONDecoder(strings.NewReader("{\"id\":1}\n\n{\"id\":2}\r\n{\"id\":3}"))
+
+	var ids []int
+
+	for {
+		var v struct {
+			ID int `json:"id"`
+		}
+
+		if err := dec.Decode(&v); err != nil {
+			if err != io.EOF {
+				t.Fatalf("Decode error. (%v)", err)
+			}
+			break
+		}
+
+		ids = append(ids, v.ID)
+	}
+
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Errorf("Unexpected result. (%v)", ids)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	reader := NewSSEReader(strings.NewReader(": comment\nretry: 1500\n\nid: 7\nevent: update\ndata: line1\ndata:line2\n\ndata: tail"))
+
+	ev, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Read error. (%v)", err)
+	}
+
+	if ev.ID != "7" || ev.Event != "update" || ev.Data != "line1\nline2" {
+		t.Errorf("Unexpected event. (%+v)", ev)
+	}
+
+	if reader.Retry.Milliseconds() != 1500 {
+		t.Errorf("Unexpected retry. (%v)", reader.Retry)
+	}
+
+	if _, err = reader.Next(); err != io.EOF {
+		t.Errorf("The incomplete event should be discarded. (%v)", err)
+	}
+}
+
+func TestHttpClient_SubscribeSSE(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		if r.Header.Get("Last-Event-ID") == "" {
+			fmt.Fprint(w, "retry: 10\nid: 1\ndata: first\n\n")
+			return
+		}
+
+		fmt.Fprintf(w, "id: 2\ndata: resumed from %s\n\n", r.Header.Get("Last-Event-ID"))
+	}))
+	defer ts.Close()
+
+	var received []string
+
+	stop := fmt.Errorf("stop")
+
+	client := NewHttpClient()
+	err := client.SubscribeSSE(context.Background(), ts.URL, nil, func(ev *SSEEvent) error {
+		received = append(received, ev.Data)
+
+		if len(received) == 2 {
+			return stop
+		}
+
+		return nil
+	})
+
+	if err != stop {
+		t.Fatalf("Unexpected error. (%v)", err)
+	}
+
+	if strings.Join(received, ",") != "first,resumed from 1" {
+		t.Errorf("Unexpected events. (%v)", received)
+	}
+}
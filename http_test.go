@@ -1,9 +1,13 @@
 package goutils
 
 import (
+	"context"
 	"fmt"
+	"github.com/pkg/errors"
 	"golang.org/x/net/publicsuffix"
+	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -145,3 +149,51 @@ func TestHttpClient_DownloadFile(t *testing.T) {
 
 	fmt.Println(resp)
 }
+
+func TestHttpClient_GetContextCancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1048576")
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	filename := filepath.Join(t.TempDir(), "cancel.bin")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	client := NewHttpClient()
+	_, err := client.GetContext(ctx, ts.URL, &HttpRequest{
+		ToFile: filename,
+	})
+
+	if err == nil {
+		t.Fatal("Expected an error after the context was cancelled.")
+	}
+
+	if IsFile(filename+".tmp") || IsFile(filename) {
+		t.Errorf("The partial file was not removed.")
+	}
+}
+
+func TestHttpClient_HttpError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}))
+	defer ts.Close()
+
+	_, err := NewHttpClient().Get(ts.URL+"/foo", nil)
+
+	var httpErr *HttpError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected an *HttpError. (%v)", err)
+	}
+
+	if httpErr.StatusCode != http.StatusNotFound || httpErr.Method != "GET" || httpErr.URL != ts.URL+"/foo" || string(httpErr.Body) != `{"error":"not found"}` {
+		t.Errorf("Unexpected error detail. (%+v)", httpErr)
+	}
+}
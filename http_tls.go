@@ -0,0 +1,171 @@
+package goutils
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultHttpTransport 未指定 Transport 时所有 HttpClient 共享的连接池。
+var defaultHttpTransport = newHttpTransport(nil)
+
+// newHttpTransport 创建支持连接复用的 Transport。(默认开启证书校验, 最低 TLS 1.2, 不读取 HTTP_PROXY/HTTPS_PROXY 环境变量)
+func newHttpTransport(cfg *tls.Config) *http.Transport {
+	if cfg == nil {
+		cfg = defaultTLSConfig()
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil
+	tr.TLSClientConfig = cfg
+	tr.MaxIdleConns = 100
+	tr.MaxIdleConnsPerHost = 16
+	tr.IdleConnTimeout = time.Second * 90
+
+	return tr
+}
+
+func defaultTLSConfig() *tls.Config {
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// withTLSConfig 复制调用方传入的 Transport 并合并 TLS 参数, 避免修改共享的 Transport (如 http.DefaultTransport)。
+func withTLSConfig(tr *http.Transport, cfg *tls.Config) *http.Transport {
+	tr = tr.Clone()
+
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = cfg
+		return tr
+	}
+
+	merged := tr.TLSClientConfig.Clone()
+
+	if cfg.RootCAs != nil {
+		merged.RootCAs = cfg.RootCAs
+	}
+
+	merged.Certificates = append(merged.Certificates, cfg.Certificates...)
+
+	if cfg.MinVersion > merged.MinVersion {
+		merged.MinVersion = cfg.MinVersion
+	}
+
+	if cfg.InsecureSkipVerify {
+		merged.InsecureSkipVerify = true
+	}
+
+	if cfg.VerifyPeerCertificate != nil {
+		merged.VerifyPeerCertificate = cfg.VerifyPeerCertificate
+	}
+
+	tr.TLSClientConfig = merged
+
+	return tr
+}
+
+func (h *HttpClient) tlsClientConfig() *tls.Config {
+	if h.tlsConfig == nil {
+		h.tlsConfig = defaultTLSConfig()
+	}
+
+	return h.tlsConfig
+}
+
+// HttpClientOptionWithRootCAs 使用自定义 CA 证书池校验服务器证书。
+func HttpClientOptionWithRootCAs(pool *x509.CertPool) HttpClientOption {
+	return func(c *HttpClient) {
+		c.tlsClientConfig().RootCAs = pool
+	}
+}
+
+// HttpClientOptionWithClientCertificate 设置客户端证书 (mTLS 双向认证)。
+func HttpClientOptionWithClientCertificate(certs ...tls.Certificate) HttpClientOption {
+	return func(c *HttpClient) {
+		c.tlsClientConfig().Certificates = append(c.tlsClientConfig().Certificates, certs...)
+	}
+}
+
+// HttpClientOptionWithMinTLSVersion 设置最低 TLS 版本。(例如: tls.VersionTLS13)
+func HttpClientOptionWithMinTLSVersion(version uint16) HttpClientOption {
+	return func(c *HttpClient) {
+		c.tlsClientConfig().MinVersion = version
+	}
+}
+
+// HttpClientOptionWithInsecureSkipVerify 跳过服务器证书校验。(仅用于测试环境!)
+func HttpClientOptionWithInsecureSkipVerify() HttpClientOption {
+	return func(c *HttpClient) {
+		c.tlsClientConfig().InsecureSkipVerify = true
+	}
+}
+
+// HttpClientOptionWithPinnedPublicKeys 证书公钥固定。
+// pins 为证书 SubjectPublicKeyInfo 的 SHA256 哈希值 (Base64 编码, 可带 "sha256/" 前缀)，证书链中任一证书匹配即通过。
+func HttpClientOptionWithPinnedPublicKeys(pins ...string) HttpClientOption {
+	return func(c *HttpClient) {
+		set := make(map[string]struct{}, len(pins))
+
+		for _, pin := range pins {
+			set[strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")] = struct{}{}
+		}
+
+		c.tlsClientConfig().VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			var certs []*x509.Certificate
+
+			for _, chain := range verifiedChains {
+				certs = append(certs, chain...)
+			}
+
+			// 跳过证书校验时 verifiedChains 为空, 直接解析服务器证书
+			if len(certs) == 0 {
+				for _, raw := range rawCerts {
+					cert, err := x509.ParseCertificate(raw)
+					if err != nil {
+						return err
+					}
+					certs = append(certs, cert)
+				}
+			}
+
+			for _, cert := range certs {
+				if _, ok := set[SPKIHash(cert)]; ok {
+					return nil
+				}
+			}
+
+			return errors.New("The server certificate does not match any pinned public key.")
+		}
+	}
+}
+
+// SPKIHash 计算证书公钥 (SubjectPublicKeyInfo) 的 SHA256 哈希值。(Base64 编码)
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// LoadCertPool 从 PEM 文件加载 CA 证书池。
+func LoadCertPool(filenames ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	for _, filename := range filenames {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New(fmt.Sprintf("No valid certificate was found. (%s)", filename))
+		}
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,55 @@
+package goutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpClient_TLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	if _, err := NewHttpClient().Get(ts.URL, nil); err == nil {
+		t.Errorf("The self-signed certificate should be rejected by default.")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	client := NewHttpClient(HttpClientOptionWithRootCAs(pool), HttpClientOptionWithPinnedPublicKeys("sha256/"+SPKIHash(ts.Certificate())))
+	if _, err := client.Get(ts.URL, nil); err != nil {
+		t.Errorf("Request errors. (%v)", err)
+	}
+
+	client = NewHttpClient(HttpClientOptionWithRootCAs(pool), HttpClientOptionWithPinnedPublicKeys("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="))
+	if _, err := client.Get(ts.URL, nil); err == nil {
+		t.Errorf("The pinned public key mismatch should be rejected.")
+	}
+}
+
+func TestHttpClient_TLSTransport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	client := NewHttpClient(HttpClientOptionWithTransport(tr), HttpClientOptionWithMinTLSVersion(tls.VersionTLS12))
+
+	// 不修改调用方传入的 Transport, 并保留其原有的 TLS 参数
+	if client.Transport == tr || tr.TLSClientConfig.MinVersion != 0 {
+		t.Errorf("The caller's transport should not be modified.")
+	}
+
+	if _, err := client.Get(ts.URL, nil); err != nil {
+		t.Errorf("Request errors. (%v)", err)
+	}
+}
@@ -0,0 +1,178 @@
+package goutils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// HttpTiming 请求耗时明细。(HttpRequest.Trace 为 true 时记录, 发生重试时为最后一次请求的耗时)
+type HttpTiming struct {
+	// DNS 解析耗时
+	DNSLookup time.Duration
+	// TCP 连接耗时
+	TCPConnect time.Duration
+	// TLS 握手耗时
+	TLSHandshake time.Duration
+	// 服务器处理耗时 (请求发送完毕至收到首字节)
+	ServerProcessing time.Duration
+	// 首字节耗时 (开始请求至收到首字节)
+	FirstByte time.Duration
+	// 响应内容传输耗时 (流式读取时为 0)
+	ContentTransfer time.Duration
+	// 总耗时
+	Total time.Duration
+	// 是否复用了连接？(复用连接时 DNS 解析、TCP 连接及 TLS 握手耗时为 0)
+	ConnReused bool
+	// 服务器地址
+	RemoteAddr string
+}
+
+func (t *HttpTiming) String() string {
+	return fmt.Sprintf("dns: %s, connect: %s, tls: %s, server: %s, ttfb: %s, transfer: %s, total: %s, reused: %v",
+		t.DNSLookup, t.TCPConnect, t.TLSHandshake, t.ServerProcessing, t.FirstByte, t.ContentTransfer, t.Total, t.ConnReused)
+}
+
+// httpTracer 通过 httptrace 收集请求各阶段的时间点。
+type httpTracer struct {
+	mu sync.Mutex
+	httpTracePoints
+}
+
+type httpTracePoints struct {
+	start, dnsStart, dnsDone, connectStart, connectDone time.Time
+	tlsStart, tlsDone, wroteRequest, firstByte          time.Time
+
+	reused     bool
+	remoteAddr string
+}
+
+func (t *httpTracer) clientTrace() *httptrace.ClientTrace {
+	now := func(f func(now time.Time)) {
+		t.mu.Lock()
+		f(time.Now())
+		t.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// 每次 (重试) 请求重新计时
+			now(func(n time.Time) { t.httpTracePoints = httpTracePoints{start: n} })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			now(func(time.Time) {
+				t.reused = info.Reused
+				if info.Conn != nil && info.Conn.RemoteAddr() != nil {
+					t.remoteAddr = info.Conn.RemoteAddr().String()
+				}
+			})
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { now(func(n time.Time) { t.dnsStart = n }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { now(func(n time.Time) { t.dnsDone = n }) },
+		ConnectStart: func(string, string) {
+			now(func(n time.Time) {
+				// 同时尝试多个地址时以首次为准
+				if t.connectStart.IsZero() {
+					t.connectStart = n
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			now(func(n time.Time) {
+				if err == nil {
+					t.connectDone = n
+				}
+			})
+		},
+		TLSHandshakeStart: func() { now(func(n time.Time) { t.tlsStart = n }) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { now(func(n time.Time) { t.tlsDone = n }) },
+		WroteRequest:      func(httptrace.WroteRequestInfo) { now(func(n time.Time) { t.wroteRequest = n }) },
+		GotFirstResponseByte: func() {
+			now(func(n time.Time) { t.firstByte = n })
+		},
+	}
+}
+
+// timing 计算耗时明细。transfer 为 false 时不计算响应内容传输耗时 (流式读取)。
+func (t *httpTracer) timing(transfer bool) *HttpTiming {
+	end := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+
+	timing := &HttpTiming{
+		DNSLookup:        since(t.dnsStart, t.dnsDone),
+		TCPConnect:       since(t.connectStart, t.connectDone),
+		TLSHandshake:     since(t.tlsStart, t.tlsDone),
+		ServerProcessing: since(t.wroteRequest, t.firstByte),
+		FirstByte:        since(t.start, t.firstByte),
+		ConnReused:       t.reused,
+		RemoteAddr:       t.remoteAddr,
+	}
+
+	if transfer {
+		timing.ContentTransfer = since(t.firstByte, end)
+		timing.Total = since(t.start, end)
+	} else {
+		timing.Total = timing.FirstByte
+	}
+
+	return timing
+}
+
+// dumpTransport 将请求及响应内容 (已隐藏敏感请求头) 写入 buf。(并发安全, 多连接下载时各分段请求共用)
+type dumpTransport struct {
+	next http.RoundTripper
+	body bool
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (t *dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if b, err := dumpRequest(req, t.body, sensitiveHeaders); err == nil {
+		t.write(b)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.write([]byte(fmt.Sprintf("Error: %v", err)))
+		return nil, err
+	}
+
+	// 压缩的响应体无法直接阅读, 仅输出响应头
+	body := t.body && resp.Header.Get("Content-Encoding") == ""
+
+	if b, err := dumpResponse(resp, body, sensitiveHeaders); err == nil {
+		t.write(b)
+	}
+
+	return resp, nil
+}
+
+func (t *dumpTransport) write(b []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf.Write(b)
+	t.buf.WriteString("\n\n")
+}
+
+// bytes 返回已记录内容的副本。
+func (t *dumpTransport) bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]byte(nil), t.buf.Bytes()...)
+}
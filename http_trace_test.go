@@ -0,0 +1,76 @@
+package goutils
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHttpClient_Trace(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	client := NewHttpClient(HttpClientOptionWithInsecureSkipVerify())
+
+	resp, err := client.Post(ts.URL, &HttpRequest{
+		Headers: map[string]interface{}{"Authorization": "Bearer token-123"},
+		Text:    "ping",
+		Trace:   true,
+		Dump:    true,
+	})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+
+	timing := resp.Timing
+	if timing == nil {
+		t.Fatalf("The request timing is missing.")
+	}
+	if timing.ConnReused || timing.TCPConnect <= 0 || timing.TLSHandshake <= 0 {
+		t.Errorf("Unexpected connection timing. (%s)", timing)
+	}
+	if timing.ServerProcessing < 20*time.Millisecond || timing.FirstByte < timing.ServerProcessing || timing.Total < timing.FirstByte {
+		t.Errorf("Unexpected timing. (%s)", timing)
+	}
+	if timing.RemoteAddr == "" {
+		t.Errorf("The remote address is missing.")
+	}
+
+	for _, s := range []string{"POST / HTTP/1.1", "ping", "HTTP/1.1 200 OK", "hello", "Authorization: ******", "Set-Cookie: ******"} {
+		if !bytes.Contains(resp.Dump, []byte(s)) {
+			t.Errorf("The dump does not contain %q. (%s)", s, resp.Dump)
+		}
+	}
+	for _, s := range []string{"token-123", "session=secret"} {
+		if bytes.Contains(resp.Dump, []byte(s)) {
+			t.Errorf("The dump contains a secret. (%s)", s)
+		}
+	}
+
+	resp, err = client.Get(ts.URL, &HttpRequest{Trace: true})
+	if err != nil {
+		t.Fatalf("Request errors. (%v)", err)
+	}
+	if !resp.Timing.ConnReused || resp.Timing.TLSHandshake != 0 {
+		t.Errorf("Expected a reused connection. (%s)", resp.Timing)
+	}
+	if resp.Dump != nil {
+		t.Errorf("Unexpected dump. (%s)", resp.Dump)
+	}
+}
+
+func TestHttpClient_TraceParallelDownload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.bin")
+
+	_, err := NewHttpClient().Get("http://127.0.0.1:1/data.bin", &HttpRequest{ToFile: filename, Connections: 4, Trace: true})
+	if err == nil || err.Error() != "Trace is not supported for parallel downloads. (Connections > 1)" {
+		t.Errorf("Unexpected error. (%v)", err)
+	}
+}
@@ -1,13 +1,8 @@
 package goutils
 
 import (
-	"crypto/md5"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
 	"fmt"
 	"github.com/pkg/errors"
-	"hash"
 	"io"
 	"os"
 	"path"
@@ -135,23 +130,9 @@ func CheckSum(filename string, algorithm HashAlgorithm, capital bool) (string, e
 
 	defer f.Close()
 
-	var h hash.Hash
-
-	switch algorithm {
-	case "md5", "MD5":
-		h = md5.New()
-	case "sha1", "SHA1":
-		h = sha1.New()
-	case "sha224", "SHA224":
-		h = sha256.New224()
-	case "sha256", "SHA256":
-		h = sha256.New()
-	case "sha384", "SHA384":
-		h = sha512.New384()
-	case "sha512", "SHA512":
-		h = sha512.New()
-	default:
-		return "", errors.New(fmt.Sprintf("Unsupported hash algorithm. (%s)", algorithm))
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
 	}
 
 	if _, err := io.Copy(h, f); err != nil {
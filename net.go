@@ -2,12 +2,14 @@ package goutils
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"golang.org/x/net/proxy"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 // httpProxy is a HTTP/HTTPS connect proxy.
@@ -90,6 +92,56 @@ func FromEnvironment() proxy.Dialer {
 	return proxy.FromEnvironment()
 }
 
+// NewProxyChainDialer 创建代理链拨号器。按顺序依次通过各代理服务器建立连接。(支持 http/https/socks5 协议)
+func NewProxyChainDialer(proxies ...string) (proxy.Dialer, error) {
+	var dialer proxy.Dialer = proxy.Direct
+
+	for _, v := range proxies {
+		u, err := url.Parse(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+
+		dialer, err = proxy.FromURL(u, dialer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return dialer, nil
+}
+
+// dialContext 使用 proxy.Dialer 建立连接。不支持 context 的拨号器在 ctx 取消时提前返回。
+func dialContext(ctx context.Context, d proxy.Dialer, network, addr string) (net.Conn, error) {
+	if cd, ok := d.(proxy.ContextDialer); ok {
+		return cd.DialContext(ctx, network, addr)
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		conn, err := d.Dial(network, addr)
+		done <- result{conn, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		go func() {
+			if ret := <-done; ret.conn != nil {
+				ret.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	case ret := <-done:
+		return ret.conn, ret.err
+	}
+}
+
 func init() {
 	proxy.RegisterDialerType("http", newHTTPProxy)
 	proxy.RegisterDialerType("https", newHTTPProxy)